@@ -0,0 +1,82 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// allowFrom wraps handler so that only clients with an IP in one of networks can use it.
+// Every other client is sent a 403. If networks is empty, every client is allowed.
+func (sbd *State) allowFrom(networks []*net.IPNet, handler http.HandlerFunc) http.HandlerFunc {
+	if len(networks) == 0 {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ip := sbd.clientIP(r); ip == nil || !containsIP(networks, ip) {
+			dlog.Printf("Denied %v access to %v\n", r.RemoteAddr, r.URL.Path)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// clientIP returns the IP of the client that made a request. If the request came
+// through one of the TrustedProxyNets, the client is the last address in the
+// X-Forwarded-For header that isn't a trusted proxy. nil is returned if the
+// address can't be parsed.
+func (sbd *State) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(sbd.Config.TrustedProxyNets, ip) {
+		return ip
+	}
+
+	// Walk back through the proxies that forwarded the request
+	forwardedFor := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for index := len(forwardedFor) - 1; index >= 0; index-- {
+		forwardedIP := net.ParseIP(strings.TrimSpace(forwardedFor[index]))
+		if forwardedIP == nil {
+			break
+		}
+
+		ip = forwardedIP
+		if !containsIP(sbd.Config.TrustedProxyNets, ip) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// containsIP returns whether ip is in any of networks
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseActiveHours parses the ActiveHours of a Service, such as '08:00-18:00', into the
+// times of day that the window starts and ends as durations since midnight. A window
+// that ends before it starts, such as '22:00-02:00', runs past midnight.
+func parseActiveHours(activeHours string) (time.Duration, time.Duration, error) {
+	parts := strings.Split(activeHours, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%q is not a window such as '08:00-18:00'", activeHours)
+	}
+
+	var window [2]time.Duration
+	for index, part := range parts {
+		timeOfDay, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("%q is not a window such as '08:00-18:00'", activeHours)
+		}
+
+		window[index] = time.Duration(timeOfDay.Hour())*time.Hour + time.Duration(timeOfDay.Minute())*time.Minute
+	}
+
+	if window[0] == window[1] {
+		return 0, 0, fmt.Errorf("the window %q must not start and end at the same time", activeHours)
+	}
+
+	return window[0], window[1], nil
+}
+
+// activeAt returns whether now is within the ActiveHours of the Service, which is always
+// true if ActiveHours isn't set.
+func (service *Service) activeAt(now time.Time) bool {
+	if service.ActiveHours == "" {
+		return true
+	}
+
+	timeOfDay := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second
+
+	// The window runs past midnight
+	if service.activeUntil < service.activeFrom {
+		return timeOfDay >= service.activeFrom || timeOfDay < service.activeUntil
+	}
+
+	return timeOfDay >= service.activeFrom && timeOfDay < service.activeUntil
+}
+
+// IsActive returns whether the Service is within its ActiveHours right now. Services
+// outside of their ActiveHours aren't checked or scored.
+func (service *Service) IsActive() bool {
+	return service.activeAt(time.Now())
+}
+
+// ActiveHoursScheduler is a thread that pauses services when they leave their ActiveHours
+// and resumes them when they enter them again. It only runs if a service has ActiveHours.
+func (sbd *State) ActiveHoursScheduler(shutdownSchedulerSignal chan interface{}) {
+	scheduled := false
+	sbd.serviceLock.RLock()
+	for hostIndex := range sbd.Hosts {
+		for serviceIndex := range sbd.Hosts[hostIndex].Services {
+			scheduled = scheduled || sbd.Hosts[hostIndex].Services[serviceIndex].ActiveHours != ""
+		}
+	}
+	sbd.serviceLock.RUnlock()
+
+	if !scheduled {
+		return
+	}
+
+	ilog.Println("Started the Active Hours Scheduler")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownSchedulerSignal:
+			ilog.Println("Shutting down the Active Hours Scheduler")
+			return
+		case <-ticker.C:
+		}
+
+		needsSync := false
+
+		sbd.serviceLock.RLock()
+		for hostIndex := range sbd.Hosts {
+			needsSync = needsSync || sbd.needsPauseSync(&sbd.Hosts[hostIndex])
+		}
+		sbd.serviceLock.RUnlock()
+
+		if !needsSync {
+			continue
+		}
+
+		sbd.serviceLock.Lock()
+		for hostIndex := range sbd.Hosts {
+			sbd.syncPaused(&sbd.Hosts[hostIndex])
+		}
+
+		sbd.signalUpdate()
+		sbd.serviceLock.Unlock()
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Annotation marks a window of time on the competition timeline, such as scheduled
+// infrastructure maintenance, so that downtime in that window can be explained.
+type Annotation struct {
+	// Start and End are when the annotated window starts and ends
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Note describes what happened during the window
+	Note string `json:"note"`
+
+	// Excused represents whether the uptime and downtime of every service are
+	// paused during the window, so that the window doesn't count toward scores.
+	Excused bool `json:"excused"`
+
+	// Admin is the admin that made the annotation
+	Admin string `json:"admin"`
+
+	// The timers that pause and resume the services for an excused window
+	timers []*time.Timer
+}
+
+// AddAnnotation adds an annotation of the window from start to end to the timeline and
+// records it in the event log. If excused is set, every service is paused while the
+// window is in progress. Excused windows can't end in the past, as the time that has
+// already been scored can't be taken back.
+func (sbd *State) AddAnnotation(start, end time.Time, note string, excused bool, admin string) error {
+	if !end.After(start) {
+		return errors.New("the end of the window must be after its start")
+	}
+
+	now := time.Now()
+	if excused && !end.After(now) {
+		return errors.New("a window that has already ended can't be excused")
+	}
+
+	annotation := Annotation{
+		Start:   start,
+		End:     end,
+		Note:    note,
+		Excused: excused,
+		Admin:   admin,
+	}
+
+	sbd.serviceLock.Lock()
+	defer sbd.serviceLock.Unlock()
+
+	if excused {
+		annotation.timers = []*time.Timer{
+			time.AfterFunc(start.Sub(now), func() { sbd.excuseWindow(note, true) }),
+			time.AfterFunc(end.Sub(now), func() { sbd.excuseWindow(note, false) }),
+		}
+	}
+
+	sbd.Annotations = append(sbd.Annotations, annotation)
+
+	message := fmt.Sprintf("Annotated %v to %v by %v: %v", start.Format(time.Kitchen),
+		end.Format(time.Kitchen), admin, note)
+	if excused {
+		message += " (excused)"
+	}
+
+	sbd.logEvent("", "", message)
+	sbd.signalUpdate()
+
+	return nil
+}
+
+// excuseWindow pauses every service when an excused window starts, and resumes them
+// once no excused window is in progress.
+func (sbd *State) excuseWindow(note string, starting bool) {
+	sbd.serviceLock.Lock()
+	defer sbd.serviceLock.Unlock()
+
+	if starting {
+		sbd.excusedWindows++
+		sbd.logEvent("", "", "Excused window started: "+note)
+	} else {
+		sbd.excusedWindows--
+		sbd.logEvent("", "", "Excused window ended: "+note)
+	}
+
+	for hostIndex := range sbd.Hosts {
+		sbd.syncPaused(&sbd.Hosts[hostIndex])
+	}
+
+	sbd.signalUpdate()
+}
+
+// shouldPause returns whether the services of host should be paused, which they are during
+// the warm up grace period, while an excused window is in progress, or while the host can't
+// be reached if ExcludeHostDownFromService is set. The caller must hold at least a read lock
+// on serviceLock.
+func (sbd *State) shouldPause(host *Host) bool {
+	return sbd.warmingUp || sbd.excusedWindows > 0 ||
+		(sbd.Config.ExcludeHostDownFromService && !host.isUp)
+}
+
+// shouldPauseService returns whether service should be paused, which it is whenever
+// shouldPause decides for its host, and outside of its ActiveHours. The caller must hold
+// at least a read lock on serviceLock.
+func (sbd *State) shouldPauseService(host *Host, service *Service, now time.Time) bool {
+	return sbd.shouldPause(host) || !service.activeAt(now)
+}
+
+// needsPauseSync returns whether any service of host is paused when it shouldn't be, or
+// the other way around. The caller must hold at least a read lock on serviceLock.
+func (sbd *State) needsPauseSync(host *Host) bool {
+	now := time.Now()
+
+	for serviceIndex := range host.Services {
+		service := &host.Services[serviceIndex]
+		if service.IsPaused() != sbd.shouldPauseService(host, service, now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// syncPaused pauses or resumes the services of host as shouldPauseService decides.
+// The caller must hold a write lock on serviceLock.
+func (sbd *State) syncPaused(host *Host) {
+	now := time.Now()
+
+	for serviceIndex := range host.Services {
+		service := &host.Services[serviceIndex]
+		service.SetPaused(sbd.shouldPauseService(host, service, now))
+	}
+}
+
+// clearAnnotations removes every annotation and stops the timers of excused windows.
+// The caller must hold a write lock on serviceLock.
+func (sbd *State) clearAnnotations() {
+	for _, annotation := range sbd.Annotations {
+		for _, timer := range annotation.timers {
+			timer.Stop()
+		}
+	}
+
+	sbd.Annotations = nil
+	sbd.excusedWindows = 0
+}
+
+// parseAnnotationTime parses the time of an annotation. This is either an RFC 3339
+// time, or a time of day such as '14:00' that is taken to be today.
+func parseAnnotationTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+
+	clock, err := time.ParseInLocation("15:04", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a time such as '14:00' or '2019-10-05T14:00:00-05:00'", value)
+	}
+
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local), nil
+}
+
+// copyAnnotations returns a copy of Annotations that can be used without holding
+// serviceLock. The caller must hold at least a read lock on serviceLock.
+func (sbd *State) copyAnnotations() []Annotation {
+	annotations := make([]Annotation, len(sbd.Annotations))
+	copy(annotations, sbd.Annotations)
+
+	return annotations
+}
+
+// annotationsResponder serves the JSON list of annotations on the timeline.
+func (sbd *State) annotationsResponder(w http.ResponseWriter, r *http.Request) {
+	sbd.serviceLock.RLock()
+	annotations := sbd.copyAnnotations()
+	sbd.serviceLock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(annotations)
+}
+
+// adminAnnotateResponder annotates the window from the `start` form value to the `end` form
+// value with the `note` form value. If the `excuse` form value is 'yes', services are paused
+// during the window so it doesn't count toward scores.
+func (sbd *State) adminAnnotateResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	start, err := parseAnnotationTime(r.FormValue("start"))
+	if err != nil {
+		http.Error(w, fmt.Sprint("start: ", err), http.StatusBadRequest)
+		return
+	}
+
+	end, err := parseAnnotationTime(r.FormValue("end"))
+	if err != nil {
+		http.Error(w, fmt.Sprint("end: ", err), http.StatusBadRequest)
+		return
+	}
+
+	note := strings.TrimSpace(r.FormValue("note"))
+	if note == "" {
+		http.Error(w, "note must describe the window", http.StatusBadRequest)
+		return
+	}
+
+	err = sbd.AddAnnotation(start, end, note, r.FormValue("excuse") == "yes", sbd.adminIdentity(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bonus is a manual adjustment to the score of a host, for objectives that aren't
+// scored by checking services, such as captured flags or resolved tickets.
+type Bonus struct {
+	// Time is the time the bonus was awarded
+	Time time.Time `json:"time"`
+
+	// Host is the name of the host the bonus was awarded to
+	Host string `json:"host"`
+
+	// Name is the name of the objective the bonus is for
+	Name string `json:"name"`
+
+	// Points are added to the score of the host. Negative points are a penalty.
+	Points int64 `json:"points"`
+
+	// Reason describes why the bonus was awarded
+	Reason string `json:"reason,omitempty"`
+
+	// Admin is the admin that awarded the bonus
+	Admin string `json:"admin"`
+}
+
+// AddBonus awards points to the host named hostName and records it in the event log.
+// An error is returned if there is no such host.
+func (sbd *State) AddBonus(hostName, name string, points int64, reason, admin string) error {
+	sbd.serviceLock.Lock()
+	defer sbd.serviceLock.Unlock()
+
+	var host *Host
+	for hostIndex := range sbd.Hosts {
+		if sbd.Hosts[hostIndex].Name == hostName {
+			host = &sbd.Hosts[hostIndex]
+			break
+		}
+	}
+
+	if host == nil {
+		return fmt.Errorf("there is no host named %q", hostName)
+	}
+
+	host.bonusPoints += points
+	sbd.Bonuses = append(sbd.Bonuses, Bonus{
+		Time:   time.Now(),
+		Host:   hostName,
+		Name:   name,
+		Points: points,
+		Reason: reason,
+		Admin:  admin,
+	})
+
+	message := fmt.Sprintf("Bonus '%v' of %+d points awarded by %v", name, points, admin)
+	if reason != "" {
+		message = fmt.Sprintf("%v: %v", message, reason)
+	}
+
+	sbd.logEvent(hostName, "", message)
+	sbd.signalUpdate()
+
+	return nil
+}
+
+// hostBonuses returns the bonuses awarded to the host named hostName.
+// The caller must hold at least a read lock on serviceLock.
+func (sbd *State) hostBonuses(hostName string) []Bonus {
+	bonuses := make([]Bonus, 0)
+	for _, bonus := range sbd.Bonuses {
+		if bonus.Host == hostName {
+			bonuses = append(bonuses, bonus)
+		}
+	}
+
+	return bonuses
+}
+
+// adminBonusResponder awards the `points` form value to the host given by the `host` form value
+// for the objective given by the `name` form value. Negative points are a penalty. The optional
+// `reason` form value is recorded with the bonus.
+func (sbd *State) adminBonusResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "name must be the name of the objective", http.StatusBadRequest)
+		return
+	}
+
+	points, err := strconv.ParseInt(strings.TrimPrefix(r.FormValue("points"), "+"), 10, 64)
+	if err != nil || points == 0 {
+		http.Error(w, "points must be a number of points such as '50' or '-25'", http.StatusBadRequest)
+		return
+	}
+
+	err = sbd.AddBonus(r.FormValue("host"), name, points, strings.TrimSpace(r.FormValue("reason")),
+		sbd.adminIdentity(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
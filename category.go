@@ -0,0 +1,82 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "sort"
+
+// uncategorized is the name of the Category of services that don't have one
+const uncategorized = "Other"
+
+// Category is the services of every host that share the same Category, for showing
+// the scoreboard grouped by the kind of service instead of by host.
+type Category struct {
+	Name     string
+	Services []CategoryService
+
+	// Up is the number of active services in the Category that are up, and Total
+	// is the number of active services in the Category.
+	Up    int
+	Total int
+}
+
+// CategoryService is a service in a Category along with the host it belongs to
+type CategoryService struct {
+	Host    Host
+	Service Service
+}
+
+// groupByCategory groups the services of hosts into categories sorted by name, with
+// services that don't have a category last. The services of each category keep the order
+// of hosts. If pingHosts is set, services of hosts that are down don't count as up.
+func groupByCategory(hosts []Host, pingHosts bool) []Category {
+	categories := make([]Category, 0)
+	indices := make(map[string]int)
+
+	for _, host := range hosts {
+		for _, service := range host.Services {
+			name := service.Category
+			if name == "" {
+				name = uncategorized
+			}
+
+			index, found := indices[name]
+			if !found {
+				index = len(categories)
+				indices[name] = index
+				categories = append(categories, Category{Name: name})
+			}
+
+			category := &categories[index]
+			category.Services = append(category.Services, CategoryService{host, service})
+
+			if service.IsActive() {
+				category.Total++
+				if service.IsUp() && (!pingHosts || host.IsUp()) {
+					category.Up++
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		if (categories[i].Name == uncategorized) != (categories[j].Name == uncategorized) {
+			return categories[j].Name == uncategorized
+		}
+
+		return categories[i].Name < categories[j].Name
+	})
+
+	return categories
+}
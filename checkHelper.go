@@ -0,0 +1,282 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// checkHelperRequest is a single line written to the stdin of the CheckHelper, asking it
+// to run a host-command. The CheckHelper may answer requests in any order.
+type checkHelperRequest struct {
+	// ID identifies the request in its checkHelperResponse
+	ID uint64 `json:"id"`
+
+	// Command is the program to run followed by its arguments
+	Command []string `json:"command"`
+
+	// Env is the environment to run Command with, as 'NAME=value' entries
+	Env []string `json:"env"`
+
+	// Dir is the working directory to run Command in. If this is empty, Command
+	// runs in the working directory of the CheckHelper.
+	Dir string `json:"dir,omitempty"`
+
+	// TimeoutMs is the number of milliseconds the CheckHelper has to answer the request
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+// checkHelperResponse is a single line read from the stdout of the CheckHelper with the
+// result of the checkHelperRequest with the same ID.
+type checkHelperResponse struct {
+	ID       uint64 `json:"id"`
+	ExitCode int    `json:"exitCode"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+
+	// Error is set if the command couldn't be run, or didn't finish in time
+	Error string `json:"error,omitempty"`
+}
+
+// checkHelper is a long-lived CheckHelper process that host-commands are sent to instead
+// of starting a new process for every check. The process is started by the first check,
+// and started again by the next check if it exits or is killed for not answering.
+type checkHelper struct {
+	command []string
+
+	lock    sync.Mutex
+	stdin   io.WriteCloser
+	process *os.Process
+	nextID  uint64
+	pending map[uint64]chan checkHelperResponse
+
+	// writeLock keeps requests from different checks from being written over each other
+	writeLock sync.Mutex
+}
+
+// newCheckHelper is a simple constructor to create a checkHelper for command, which is
+// the program followed by its arguments, that hasn't been started yet
+func newCheckHelper(command []string) *checkHelper {
+	return &checkHelper{
+		command: command,
+		pending: make(map[uint64]chan checkHelperResponse),
+	}
+}
+
+// start starts the CheckHelper process and the thread that reads its responses.
+// The caller must hold lock.
+func (helper *checkHelper) start() error {
+	cmd := exec.Command(helper.command[0], helper.command[1:]...)
+
+	// Run the CheckHelper in its own process group so that the commands it started
+	// are killed along with it if it stops answering
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	ilog.Println("Started the check helper", strings.Join(helper.command, " "))
+
+	helper.stdin = stdin
+	helper.process = cmd.Process
+
+	go helper.readResponses(cmd, stdin, stdout)
+
+	return nil
+}
+
+// readResponses passes each response of the CheckHelper to the check that is waiting for it
+// until the process exits. Every check still waiting then fails, by closing the channel it
+// waits on, and the next check starts the process again.
+func (helper *checkHelper) readResponses(cmd *exec.Cmd, stdin io.WriteCloser, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var response checkHelperResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			elog.Println("Ignoring a malformed response from the check helper:", err)
+			continue
+		}
+
+		helper.lock.Lock()
+		if waiting, found := helper.pending[response.ID]; found {
+			delete(helper.pending, response.ID)
+			waiting <- response
+		}
+		helper.lock.Unlock()
+	}
+
+	stdin.Close()
+	err := cmd.Wait()
+
+	helper.lock.Lock()
+	defer helper.lock.Unlock()
+
+	elog.Println("The check helper exited:", err)
+
+	if helper.stdin == stdin {
+		helper.stdin = nil
+		helper.process = nil
+	}
+
+	for id, waiting := range helper.pending {
+		delete(helper.pending, id)
+		close(waiting)
+	}
+}
+
+// kill kills the process group of the CheckHelper that was started with stdin and process,
+// unless it has already exited. readResponses then fails every check still waiting on it,
+// and the next check starts the CheckHelper again. The caller must hold lock.
+func (helper *checkHelper) kill(stdin io.WriteCloser, process *os.Process) {
+	if helper.stdin != stdin {
+		return
+	}
+
+	elog.Println("Killing the check helper because it didn't answer before the service timeout")
+
+	syscall.Kill(-process.Pid, syscall.SIGKILL)
+}
+
+// run asks the CheckHelper to run command with the environment env in dir, and waits up to
+// timeout for the request to be written and answered. An error is returned if the
+// CheckHelper can't be asked, or doesn't answer in time, in which case it is killed.
+func (helper *checkHelper) run(command []string, env []string, dir string,
+	timeout time.Duration) (checkHelperResponse, error) {
+
+	// The timeout covers writing the request too, since a CheckHelper that stops
+	// reading its stdin would block the write forever
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	waiting := make(chan checkHelperResponse, 1)
+
+	helper.lock.Lock()
+
+	if helper.stdin == nil {
+		if err := helper.start(); err != nil {
+			helper.lock.Unlock()
+			return checkHelperResponse{}, fmt.Errorf("failed to start the check helper: %v", err)
+		}
+	}
+
+	helper.nextID++
+	request := checkHelperRequest{
+		ID:        helper.nextID,
+		Command:   command,
+		Env:       env,
+		Dir:       dir,
+		TimeoutMs: int64(timeout / time.Millisecond),
+	}
+
+	helper.pending[request.ID] = waiting
+	stdin, process := helper.stdin, helper.process
+	helper.lock.Unlock()
+
+	line, err := json.Marshal(request)
+	if err != nil {
+		helper.lock.Lock()
+		delete(helper.pending, request.ID)
+		helper.lock.Unlock()
+
+		return checkHelperResponse{}, fmt.Errorf("failed to send the check to the check helper: %v", err)
+	}
+
+	// The request is written from another thread without holding lock, so that responses
+	// are still read and the timeout still applies while the CheckHelper is slow to read
+	// requests. Killing the CheckHelper fails a write that is stuck.
+	written := make(chan error, 1)
+	go func() {
+		helper.writeLock.Lock()
+		_, err := stdin.Write(append(line, '\n'))
+		helper.writeLock.Unlock()
+
+		written <- err
+	}()
+
+	for {
+		select {
+		case err := <-written:
+			if err != nil {
+				helper.lock.Lock()
+				delete(helper.pending, request.ID)
+				helper.lock.Unlock()
+
+				return checkHelperResponse{}, fmt.Errorf("failed to send the check to the check helper: %v", err)
+			}
+		case response, answered := <-waiting:
+			if !answered {
+				return checkHelperResponse{}, errors.New("the check helper exited before it answered")
+			}
+
+			return response, nil
+		case <-timer.C:
+			helper.lock.Lock()
+			delete(helper.pending, request.ID)
+			helper.kill(stdin, process)
+			helper.lock.Unlock()
+
+			return checkHelperResponse{}, errors.New("the check helper didn't answer before the service timeout")
+		}
+	}
+}
+
+// helperHostCommand sends command to the CheckHelper to run with the environment env in
+// WorkDir, and returns its output and the error it exited with, in the same way as
+// execHostCommand. An error is returned if the CheckHelper didn't answer.
+func (service *Service) helperHostCommand(command []string, env []string, timeout time.Duration,
+	config *Config) (commandResult, error) {
+
+	response, err := config.checkHelper.run(command, env, service.WorkDir, timeout)
+	if err != nil {
+		return commandResult{}, err
+	}
+
+	stdout := limitedBuffer{limit: config.MaxResponseBytes}
+	stderr := limitedBuffer{limit: config.MaxResponseBytes}
+	stdout.Write([]byte(response.Stdout))
+	stderr.Write([]byte(response.Stderr))
+
+	var exitErr error
+	if response.Error != "" {
+		exitErr = errors.New(response.Error)
+	} else if response.ExitCode != 0 {
+		exitErr = fmt.Errorf("exit status %v", response.ExitCode)
+	}
+
+	return commandResult{stdout.Bytes(), stderr.Bytes(), exitErr}, nil
+}
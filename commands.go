@@ -0,0 +1,82 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CommandCheck is a single command of a 'host-command' Service's Commands. Command
+// is run on this host and Response must match its output for the command to pass.
+type CommandCheck struct {
+	// Command is the command to run
+	Command string `yaml:"command"`
+
+	// Response is a regular expression that must match the output of Command chosen
+	// by the Service's MatchStream. This is optional if the Service uses UseExitCode.
+	Response string `yaml:"response"`
+}
+
+// checkCommands runs each of the Service's Commands in order. The Service is up only if
+// every command passes. Unless RunAllCommands is set, the check stops at the first command
+// that fails. Every command shares the service timeout, so each command only gets the time
+// that the commands before it left over. Every command runs with the environment env. If
+// the check fails, the details of the failures are returned.
+func (service *Service) checkCommands(env []string, config *Config) (bool, string) {
+	var (
+		deadline = time.Now().Add(config.ServiceTimeout)
+		failures = make([]string, 0, len(service.Commands))
+	)
+
+	for index, check := range service.Commands {
+		// Leave each command enough time to be stopped gracefully if it runs too long
+		timeLeft := time.Until(deadline)
+		if timeLeft <= config.CommandKillGrace {
+			failures = append(failures, fmt.Sprintf("command %v: the service timeout was reached "+
+				"before it could run", index+1))
+			break
+		}
+
+		if passed, details := service.runHostCommand(check.Command, check.Response, env, timeLeft, config); !passed {
+			failures = append(failures, fmt.Sprintf("command %v: %v", index+1, details))
+
+			if !service.RunAllCommands {
+				break
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return false, strings.Join(failures, "; ")
+	}
+
+	return true, ""
+}
+
+// hostCommands returns every command that the Service runs on this host
+func (service *Service) hostCommands() []string {
+	if len(service.Commands) == 0 {
+		return []string{service.Command}
+	}
+
+	commands := make([]string, 0, len(service.Commands))
+	for _, check := range service.Commands {
+		commands = append(commands, check.Command)
+	}
+
+	return commands
+}
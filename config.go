@@ -15,13 +15,75 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// The default for the most bytes read from a service before matching its response
+const defaultMaxResponseBytes = 64 * 1024
+
+// The default for how long a host-command is given to exit after SIGTERM before it is killed
+const defaultCommandKillGrace = 1 * time.Second
+
+// The defaults for how long the webservers wait on clients
+const (
+	defaultHTTPReadTimeout  = 10 * time.Second
+	defaultHTTPWriteTimeout = 30 * time.Second
+	defaultHTTPIdleTimeout  = 120 * time.Second
+)
+
+// The defaults for the colors of the built in scoreboard
+const (
+	defaultUpColor         = "green"
+	defaultDownColor       = "red"
+	defaultBackgroundColor = "#133f7c"
+)
+
+// hostname matches the hostnames that can be given in the ip: field of a host
+var hostname = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*\.?$`)
+
+// isHostname returns whether name is a hostname that can be given in the ip: field of a
+// host. The last label must have a letter in it so that a mistyped IP address such as
+// '10.0.0.256' isn't taken to be a hostname.
+func isHostname(name string) bool {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+
+	return hostname.MatchString(name) && strings.ContainsAny(labels[len(labels)-1],
+		"abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+// cssColor matches the CSS colors that can be given for the colors of the built in
+// scoreboard. Either a hex color, a color name, or an rgb(), rgba(), hsl() or hsla() color.
+var cssColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|(rgb|rgba|hsl|hsla)\([0-9.,%\s]+\))$`)
+
+// The default for the shortest pingInterval and serviceInterval that are allowed
+const defaultMinInterval = 5 * time.Second
+
+// The default NATS subject that updates are published on
+const defaultNATSSubject = "goscore.updates"
+
+// The default for how long the onStateChange command may run before it is killed
+const defaultOnStateChangeTimeout = 30 * time.Second
+
+// The defaults for the uptime percentages that shade uptimes as good, or as a warning
+const (
+	defaultUptimeGoodPercent = 95
+	defaultUptimeWarnPercent = 80
+)
+
 // YamlConfig is a struct to represent the yaml config. This type is
 // passed directly to yaml.v2 for parsing the physical
 // config file into active memory which is used to create State
@@ -41,21 +103,211 @@ func (err configValidationError) Error() string {
 	return string(err)
 }
 
+// findMissingCommands returns the programs that host-command services run that
+// can't be found in $PATH. Each program is only listed once.
+func findMissingCommands(hosts []Host) []string {
+	missing := make([]string, 0)
+	checked := make(map[string]bool)
+
+	for _, host := range hosts {
+		for _, service := range host.Services {
+			if service.Protocol != "host-command" {
+				continue
+			}
+
+			for _, command := range service.hostCommands() {
+				words, err := splitCommand(command)
+				if err != nil {
+					continue
+				}
+
+				program := words[0]
+
+				// Relative paths to programs are found from the service's working directory
+				if service.WorkDir != "" && strings.Contains(program, "/") && !filepath.IsAbs(program) {
+					program = filepath.Join(service.WorkDir, program)
+				}
+
+				if checked[program] {
+					continue
+				}
+
+				checked[program] = true
+
+				if _, err := exec.LookPath(program); err != nil {
+					missing = append(missing, program)
+				}
+			}
+		}
+	}
+
+	return missing
+}
+
+// parseCIDRList parses a comma separated list of CIDRs, such as '10.0.0.0/8, 192.168.1.0/24'.
+// A single IP is treated as a network holding only that IP. An empty list returns no networks.
+func parseCIDRList(list string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+
+	for _, cidr := range strings.Split(list, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// parseDuration parses a duration like time.ParseDuration does, and also accepts a number of
+// days at the start of it, such as '2d' or '1d12h', since competitions can run over days.
+func parseDuration(duration string) (time.Duration, error) {
+	if index := strings.Index(duration, "d"); index > 0 {
+		if days, err := strconv.ParseUint(duration[:index], 10, 32); err == nil {
+			duration = fmt.Sprintf("%vh%v", days*24, duration[index+1:])
+		}
+	}
+
+	return time.ParseDuration(duration)
+}
+
+// parseCheckOffset parses the phase offset of a check cycle with the given interval. This is
+// either 'half' for half of the interval, or a duration of at least 0s that is shorter than
+// the interval. An empty offset is no offset.
+func parseCheckOffset(offset string, interval time.Duration) (time.Duration, error) {
+	switch offset {
+	case "":
+		return 0, nil
+	case "half":
+		return interval / 2, nil
+	}
+
+	duration, err := parseDuration(offset)
+	if err != nil || duration < 0 || duration >= interval {
+		return 0, fmt.Errorf("%q is not 'half' or a duration from 0s up to the interval", offset)
+	}
+
+	return duration, nil
+}
+
 // This function simple Opens the config.yaml file and parses it
 // into the YamlConfig type, then returns that type.
 func initConfig() (YamlConfig, error) {
-	var (
-		configFile *os.File
-		config     YamlConfig
-	)
+	var config YamlConfig
+
+	files, err := configFiles()
+	if err != nil {
+		return config, err // Returns *PathError
+	}
+
+	if len(files) == 1 {
+		return config, decodeConfigFile(files[0], &config) // Only relevant error is *TypeError
+	}
+
+	// Hosts are merged in the order of the files, and later files override the
+	// 'config:' keys of earlier files
+	config.Config = make(map[string]string)
+	hostFiles := make(map[string]string)
+
+	for _, file := range files {
+		var fileConfig YamlConfig
+		if err := decodeConfigFile(file, &fileConfig); err != nil {
+			if _, isPathError := err.(*os.PathError); isPathError {
+				return config, err
+			}
+
+			return config, fmt.Errorf("%v: %v", file, err)
+		}
+
+		for _, host := range fileConfig.Hosts {
+			for _, key := range []string{"name " + host.Name, "IP " + host.IP} {
+				if strings.HasSuffix(key, " ") { // Missing fields are reported when validating
+					continue
+				}
+
+				if otherFile, found := hostFiles[key]; found && otherFile != file {
+					return config, configValidationError(fmt.Sprintf("The host with the %v is defined in "+
+						"both %v and %v", key, otherFile, file))
+				}
+
+				hostFiles[key] = file
+			}
+		}
+
+		config.Hosts = append(config.Hosts, fileConfig.Hosts...)
+		for key, value := range fileConfig.Config {
+			config.Config[key] = value
+		}
+	}
+
+	return config, nil
+}
+
+// configFiles returns the config files to read. The -c flag is either a single file, a
+// comma separated list of files, or a directory whose '.yaml' and '.yml' files are read in
+// order of their names. If the single file can't be opened, config.yaml in the current
+// working directory is tried instead.
+func configFiles() ([]string, error) {
+	location := defaultConfigFileLocation
+
+	if strings.Contains(location, ",") {
+		files := make([]string, 0)
+		for _, file := range strings.Split(location, ",") {
+			if file = strings.TrimSpace(file); file != "" {
+				files = append(files, file)
+			}
+		}
+
+		return files, nil
+	}
+
+	if info, err := os.Stat(location); err == nil && info.IsDir() {
+		files := make([]string, 0)
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, _ := filepath.Glob(filepath.Join(location, pattern))
+			files = append(files, matches...)
+		}
+
+		if len(files) == 0 {
+			return nil, &os.PathError{Op: "open", Path: filepath.Join(location, "*.yaml"), Err: os.ErrNotExist}
+		}
+
+		sort.Strings(files)
+
+		return files, nil
+	}
 
 	// Test each config file option.
-	if f, err := os.Open(defaultConfigFileLocation); err == nil {
-		configFile = f
+	if f, err := os.Open(location); err == nil {
+		f.Close()
+		return []string{location}, nil
 	} else if f, err := os.Open(defaultConfigFileName); err == nil {
-		configFile = f
+		f.Close()
+		return []string{defaultConfigFileName}, nil
 	} else {
-		return config, err // Returns *PathError
+		return nil, err
+	}
+}
+
+// decodeConfigFile decodes the YAML config file named file into config
+func decodeConfigFile(file string, config *YamlConfig) error {
+	configFile, err := os.Open(file)
+	if err != nil {
+		return err
 	}
 
 	defer configFile.Close()
@@ -63,10 +315,73 @@ func initConfig() (YamlConfig, error) {
 	dlog.Println("Opened config:", configFile.Name())
 
 	// Attempt to decode the config into a go type
-	return config, yaml.NewDecoder(configFile).Decode(&config) // Only relevant error is *TypeError
+	return yaml.NewDecoder(configFile).Decode(config)
+}
+
+// The version of the config file that this build of goscore writes and supports
+const currentConfigVersion = 2
+
+// configVersionChanges describes what changed in each version of the config file, so that
+// someone reusing an older config knows what to look at. Configs without a 'version:' field
+// are version 1.
+var configVersionChanges = map[int]string{
+	2: "The 'version:' field was added under 'config:'. Host-commands are now sent SIGTERM " +
+		"'commandKillGrace:' before 'serviceTimeout:' runs out instead of only SIGKILL, and " +
+		"services are now checked every 'serviceInterval:' instead of every 'pingInterval:', and " +
+		"neither interval is multiplied by a second anymore.",
+}
+
+// validateConfigVersion checks the 'version:' field under 'config:'. A config that is newer than
+// this build supports is an error, and a config that is older is warned about with what changed.
+func (config *YamlConfig) validateConfigVersion() error {
+	version := 1
+	if configVersion := config.Config["version"]; configVersion != "" {
+		if parsedVersion, err := strconv.Atoi(configVersion); err == nil && parsedVersion >= 1 {
+			version = parsedVersion
+		} else {
+			return configValidationError("The 'version:' field under 'config:' must be a positive number")
+		}
+	}
+
+	if version > currentConfigVersion {
+		return configValidationError(fmt.Sprintf("The config is version %v, but this goscore only supports "+
+			"up to version %v. Upgrade goscore or generate a new config with -buildcfg", version,
+			currentConfigVersion))
+	}
+
+	if version < currentConfigVersion {
+		elog.Printf("Warning: The config is version %v, but the current version is %v. Set 'version: \"%v\"' "+
+			"under 'config:' once the config has been checked against these changes:\n", version,
+			currentConfigVersion, currentConfigVersion)
+
+		for changedVersion := version + 1; changedVersion <= currentConfigVersion; changedVersion++ {
+			elog.Printf("  Version %v: %v\n", changedVersion, configVersionChanges[changedVersion])
+		}
+	}
+
+	return nil
+}
+
+// maxResponseBytes returns the optional 'maxResponseBytes:' field under 'config:', or
+// defaultMaxResponseBytes if it isn't set
+func (config *YamlConfig) maxResponseBytes() (int64, error) {
+	maxBytes := config.Config["maxResponseBytes"]
+	if maxBytes == "" {
+		return defaultMaxResponseBytes, nil
+	}
+
+	if maxResponseBytes, err := strconv.ParseInt(maxBytes, 10, 64); err == nil && maxResponseBytes > 0 {
+		return maxResponseBytes, nil
+	}
+
+	return 0, configValidationError("The 'maxResponseBytes:' field under 'config:' must be a positive number")
 }
 
 func (config *YamlConfig) validateConfig() error {
+	if err := config.validateConfigVersion(); err != nil {
+		return err
+	}
+
 	// Test for pingHosts
 	if len(config.Config["pingHosts"]) == 0 {
 		return configValidationError("You must include the 'pingHosts:' field under 'config:'")
@@ -89,6 +404,11 @@ func (config *YamlConfig) validateConfig() error {
 		return configValidationError("You must define the 'serviceTimeout:' field under 'config:'")
 	}
 
+	maxResponseBytes, err := config.maxResponseBytes()
+	if err != nil {
+		return err
+	}
+
 	if len(config.Config["managementUsername"]) == 0 {
 		return configValidationError("You must define the 'managementUsername:' field under 'config:'")
 	}
@@ -113,17 +433,42 @@ func (config *YamlConfig) validateConfig() error {
 				"in the ip: field.", host.Name))
 		}
 
+		if net.ParseIP(host.IP) == nil && !isHostname(host.IP) {
+			return configValidationError(fmt.Sprintf("The ip: field for %v must be a valid IP address "+
+				"or hostname", host.Name))
+		}
+
+		if ipv6 := net.ParseIP(host.IPv6); host.IPv6 != "" && (ipv6 == nil || ipv6.To4() != nil) {
+			return configValidationError(fmt.Sprintf("The ipv6: field for %v must be a valid "+
+				"IPv6 address", host.Name))
+		}
+
+		if host.BackupIP != "" && net.ParseIP(host.BackupIP) == nil {
+			return configValidationError(fmt.Sprintf("The backupIP: field for %v must be a valid "+
+				"IP address", host.Name))
+		}
+
 		if len(host.Services) == 0 {
 			return configValidationError(fmt.Sprintf("You must define at least one "+
 				"Service for %v under the services: field", host.Name))
 		}
 
+		// Services are identified by their name within a host, so the names must be unique
+		serviceNames := make(map[string]bool, len(host.Services))
+
 		for _, service := range host.Services {
 			if len(service.Name) == 0 {
 				return configValidationError(fmt.Sprintf("You must define the name of the "+
 					"service for %v under the service: field", host.Name))
 			}
 
+			if serviceNames[service.Name] {
+				return configValidationError(fmt.Sprintf("The service %v is defined more than once "+
+					"on %v. Each service on a host must have a unique name", service.Name, host.Name))
+			}
+
+			serviceNames[service.Name] = true
+
 			if len(service.Protocol) == 0 {
 				return configValidationError(fmt.Sprintf("You must define the protocol "+
 					"to use to test %v on %v", service.Name, host.Name))
@@ -134,9 +479,150 @@ func (config *YamlConfig) validateConfig() error {
 					"connet to to test %v on %v", service.Name, host.Name))
 			}
 
-			if service.Protocol == "host-command" && (len(service.Command) == 0 || len(service.Response) == 0) {
-				return configValidationError(fmt.Sprintf("You must speicify a command and a response to "+
-					"run to test %v on %v in host-command mode", service.Name, host.Name))
+			if service.SendStringFormat != "" && service.SendStringFormat != "raw" &&
+				service.SendStringFormat != "crlf-terminated" {
+				return configValidationError(fmt.Sprintf("The sendStringFormat for %v on %v must be "+
+					"either 'raw' or 'crlf-terminated'", service.Name, host.Name))
+			}
+
+			if service.MatchField != "" && service.MatchField != "status" &&
+				service.MatchField != "headers" && service.MatchField != "body" {
+				return configValidationError(fmt.Sprintf("The matchField for %v on %v must be "+
+					"one of 'status', 'headers', or 'body'", service.Name, host.Name))
+			}
+
+			if service.Network != "" && ((service.Network != "tcp" && service.Network != "tcp4" &&
+				service.Network != "tcp6") || (service.Protocol != "tcp" && service.Protocol != "http" &&
+				service.Protocol != "https")) {
+				return configValidationError(fmt.Sprintf("The network for %v on %v must be one of "+
+					"'tcp', 'tcp4', or 'tcp6', and can only be used when the protocol is 'tcp', 'http', "+
+					"or 'https'", service.Name, host.Name))
+			}
+
+			if service.MatchStream != "" && ((service.MatchStream != "any" && service.MatchStream != "stdout" &&
+				service.MatchStream != "stderr") || service.Protocol != "host-command") {
+				return configValidationError(fmt.Sprintf("The matchStream for %v on %v must be one of "+
+					"'any', 'stdout', or 'stderr', and can only be used when the protocol is 'host-command'",
+					service.Name, host.Name))
+			}
+
+			if service.ReadBytes < 0 || (service.ReadBytes > 0 && service.Protocol != "tcp" &&
+				service.Protocol != "udp") {
+				return configValidationError(fmt.Sprintf("The readBytes for %v on %v must be a positive "+
+					"number, and can only be used when the protocol is 'tcp' or 'udp'", service.Name, host.Name))
+			}
+
+			if service.ReadBytes > maxResponseBytes {
+				return configValidationError(fmt.Sprintf("The readBytes for %v on %v can't be more than "+
+					"'maxResponseBytes:' (%v)", service.Name, host.Name, maxResponseBytes))
+			}
+
+			if len(service.Script) > 0 && service.Protocol != "tcp" && service.Protocol != "udp" {
+				return configValidationError(fmt.Sprintf("The script for %v on %v can only be used "+
+					"when the protocol is 'tcp' or 'udp'", service.Name, host.Name))
+			}
+
+			for index, step := range service.Script {
+				if len(step.Send) == 0 && len(step.Expect) == 0 {
+					return configValidationError(fmt.Sprintf("Step %v of the script for %v on %v must "+
+						"define at least one of send: or expect:", index+1, service.Name, host.Name))
+				}
+
+				if _, err := regexp.Compile(step.Expect); err != nil {
+					return configValidationError(fmt.Sprintf("The expect: of step %v of the script for "+
+						"%v on %v is not a valid regular expression: %v", index+1, service.Name, host.Name, err))
+				}
+			}
+
+			if len(service.Commands) > 0 {
+				if service.Protocol != "host-command" || len(service.Command) > 0 || len(service.Response) > 0 {
+					return configValidationError(fmt.Sprintf("The commands for %v on %v can only be used "+
+						"when the protocol is 'host-command', without a command or response", service.Name,
+						host.Name))
+				}
+
+				for index, check := range service.Commands {
+					if len(check.Command) == 0 || (len(check.Response) == 0 && !service.UseExitCode) {
+						return configValidationError(fmt.Sprintf("Command %v of the commands for %v on %v "+
+							"must define a command: and a response:, or the service must use useExitCode",
+							index+1, service.Name, host.Name))
+					}
+
+					if _, err := regexp.Compile(check.Response); err != nil {
+						return configValidationError(fmt.Sprintf("The response: of command %v of the commands "+
+							"for %v on %v is not a valid regular expression: %v", index+1, service.Name,
+							host.Name, err))
+					}
+				}
+			} else if service.Protocol == "host-command" && (len(service.Command) == 0 ||
+				(len(service.Response) == 0 && !service.UseExitCode)) {
+				return configValidationError(fmt.Sprintf("You must speicify a command and a response, or "+
+					"useExitCode, to test %v on %v in host-command mode", service.Name, host.Name))
+			}
+
+			if service.Protocol == "host-command" {
+				for _, command := range service.hostCommands() {
+					if _, err := splitCommand(command); err != nil {
+						return configValidationError(fmt.Sprintf("The command %q for %v on %v is invalid: %v",
+							command, service.Name, host.Name, err))
+					}
+				}
+			}
+
+			if service.UDPProbe != "" && service.UDPProbe != "raw" {
+				if _, found := udpProbes[service.UDPProbe]; !found || service.Protocol != "udp" {
+					return configValidationError(fmt.Sprintf("The udpProbe for %v on %v must be one of "+
+						"'ntp', 'dns', 'snmp', or 'raw', and can only be used when the protocol is 'udp'",
+						service.Name, host.Name))
+				}
+
+				if len(service.Response) > 0 || len(service.Script) > 0 {
+					return configValidationError(fmt.Sprintf("A response or script can't be used with the "+
+						"%v udpProbe for %v on %v", service.UDPProbe, service.Name, host.Name))
+				}
+			}
+
+			if service.ExpectClosed && (service.Protocol != "tcp" || len(service.Command) > 0 ||
+				len(service.Response) > 0 || len(service.Script) > 0) {
+				return configValidationError(fmt.Sprintf("expectClosed can only be used to test %v on %v "+
+					"when the protocol is 'tcp', without a command, response, or script",
+					service.Name, host.Name))
+			}
+
+			if service.RequireHostUp && config.Config["pingHosts"] != "yes" {
+				return configValidationError(fmt.Sprintf("requireHostUp can only be used to test %v on %v "+
+					"when 'pingHosts:' is set to yes", service.Name, host.Name))
+			}
+
+			if (len(service.Env) > 0 || service.WorkDir != "") && service.Protocol != "host-command" {
+				return configValidationError(fmt.Sprintf("env and workdir can only be used to test %v on %v "+
+					"when the protocol is 'host-command'", service.Name, host.Name))
+			}
+
+			for name := range service.Env {
+				if name == "" || strings.ContainsAny(name, "= ") {
+					return configValidationError(fmt.Sprintf("The env for %v on %v has an invalid "+
+						"variable name %q", service.Name, host.Name, name))
+				}
+			}
+
+			if service.WorkDir != "" {
+				if info, err := os.Stat(service.WorkDir); err != nil || !info.IsDir() {
+					return configValidationError(fmt.Sprintf("The workdir for %v on %v must be an "+
+						"existing directory", service.Name, host.Name))
+				}
+			}
+
+			if service.UseExitCode && service.Protocol != "host-command" {
+				return configValidationError(fmt.Sprintf("useExitCode can only be used to test %v on %v "+
+					"when the protocol is 'host-command'", service.Name, host.Name))
+			}
+
+			if service.ActiveHours != "" {
+				if _, _, err := parseActiveHours(service.ActiveHours); err != nil {
+					return configValidationError(fmt.Sprintf("The activeHours for %v on %v are invalid: %v",
+						service.Name, host.Name, err))
+				}
 			}
 		}
 	}
@@ -151,6 +637,18 @@ func parseConfigToScoreboard(config *YamlConfig, scoreboard *State) error {
 		return err
 	}
 
+	// Determine the optional minInterval option from the config file. This keeps
+	// a typo like '100ms' from flooding the competition network with checks.
+	minInterval := defaultMinInterval
+	if interval := config.Config["minInterval"]; interval != "" {
+		if parsedInterval, err := parseDuration(interval); err == nil && parsedInterval >= 0 {
+			minInterval = parsedInterval
+		} else {
+			return configValidationError("The 'minInterval:' field under 'config:' must be a duration " +
+				"of at least 0s")
+		}
+	}
+
 	// Determine if the user has set the ping option in the config file.
 	if config.Config["pingHosts"] != "yes" {
 		scoreboard.Config.PingHosts = false // Deactivates all the ping functionality of the program
@@ -159,7 +657,7 @@ func parseConfigToScoreboard(config *YamlConfig, scoreboard *State) error {
 		scoreboard.Config.PingHosts = true // Activates the ping functionality of the program
 
 		// Determine the required pingInterval option from the config file
-		if pingDuration, err := time.ParseDuration(config.Config["pingInterval"]); err == nil {
+		if pingDuration, err := parseDuration(config.Config["pingInterval"]); err == nil {
 			scoreboard.Config.TimeBetweenPingChecks = pingDuration
 
 		} else { // The option was not found
@@ -167,16 +665,85 @@ func parseConfigToScoreboard(config *YamlConfig, scoreboard *State) error {
 		}
 
 		// Determine the required pingTimeout option from the config file
-		if ptimeout, err := time.ParseDuration(config.Config["pingTimeout"]); err == nil {
+		if ptimeout, err := parseDuration(config.Config["pingTimeout"]); err == nil {
 			scoreboard.Config.PingTimeout = ptimeout
 
 		} else { // The option was not found
 			return configValidationError(fmt.Sprint("Failed to parse pingTimeout in config file:", err))
 		}
+
+		// Determine the optional pingRetries option from the config file
+		if retries := config.Config["pingRetries"]; retries != "" {
+			if pingRetries, err := strconv.Atoi(retries); err == nil && pingRetries >= 0 {
+				scoreboard.Config.PingRetries = pingRetries
+			} else {
+				return configValidationError("The 'pingRetries:' field under 'config:' must be a number of at least 0")
+			}
+		}
+
+		if scoreboard.Config.TimeBetweenPingChecks < minInterval {
+			return configValidationError(fmt.Sprintf("The 'pingInterval:' field under 'config:' must be at "+
+				"least %v. Change 'minInterval:' to allow shorter intervals", minInterval))
+		}
+
+		if scoreboard.Config.PingTimeout <= 0 ||
+			scoreboard.Config.PingTimeout >= scoreboard.Config.TimeBetweenPingChecks {
+			return configValidationError("The 'pingTimeout:' field under 'config:' must be greater than 0 " +
+				"and shorter than 'pingInterval:' so pings don't overlap")
+		}
+
+		// A host that doesn't answer is pinged on every one of its addresses on every retry
+		for _, host := range config.Hosts {
+			if host.pingBudget(scoreboard.Config.PingTimeout, scoreboard.Config.PingRetries) >=
+				scoreboard.Config.TimeBetweenPingChecks {
+				return configValidationError(fmt.Sprintf("Every ping retry waits for 'pingTimeout:' on each "+
+					"address of %v, and a hostname is looked up within 'pingTimeout:' too, so pinging it can "+
+					"take %v. Lower 'pingRetries:' or 'pingTimeout:', or raise 'pingInterval:'", host.Name,
+					host.pingBudget(scoreboard.Config.PingTimeout, scoreboard.Config.PingRetries)))
+			}
+		}
+
+		scoreboard.Config.PingStagger = config.Config["pingStagger"] == "yes"
+
+		// Determine the optional pingOffset option from the config file
+		if pingOffset, err := parseCheckOffset(config.Config["pingOffset"], scoreboard.Config.TimeBetweenPingChecks); err == nil {
+			scoreboard.Config.PingCheckOffset = pingOffset
+		} else {
+			return configValidationError(fmt.Sprint("The 'pingOffset:' field under 'config:' is invalid: ", err))
+		}
+
+		// Determine the optional pingMethod option from the config file
+		switch pingMethod := config.Config["pingMethod"]; pingMethod {
+		case "", "icmp":
+			scoreboard.Config.PingMethod = "icmp"
+		case "udp", "tcp":
+			scoreboard.Config.PingMethod = pingMethod
+		default:
+			return configValidationError(fmt.Sprintf("Unknown pingMethod '%v' in 'config:'. "+
+				"Must be one of 'icmp', 'udp', or 'tcp'", pingMethod))
+		}
+
+		if scoreboard.Config.PingMethod == "tcp" {
+			if probePort := config.Config["pingProbePort"]; probePort != "" {
+				scoreboard.Config.PingProbePort = probePort
+			} else {
+				return configValidationError("You must define the 'pingProbePort:' field under " +
+					"'config:' when 'pingMethod:' is 'tcp'")
+			}
+		}
+	}
+
+	// Determine the optional maxConcurrentPings option from the config file
+	if maxPings := config.Config["maxConcurrentPings"]; maxPings != "" {
+		if maxConcurrentPings, err := strconv.Atoi(maxPings); err == nil && maxConcurrentPings >= 1 {
+			scoreboard.Config.MaxConcurrentPings = maxConcurrentPings
+		} else {
+			return configValidationError("The 'maxConcurrentPings:' field under 'config:' must be a number of at least 1")
+		}
 	}
 
 	// Determine the required serviceInterval option from the config file
-	if serviceDuration, err := time.ParseDuration(config.Config["serviceInterval"]); err == nil {
+	if serviceDuration, err := parseDuration(config.Config["serviceInterval"]); err == nil {
 		scoreboard.Config.TimeBetweenServiceChecks = serviceDuration
 
 	} else { // The option was not found
@@ -184,13 +751,140 @@ func parseConfigToScoreboard(config *YamlConfig, scoreboard *State) error {
 	}
 
 	// Check for ServiceTimeout
-	if stimeout, err := time.ParseDuration(config.Config["serviceTimeout"]); err == nil {
+	if stimeout, err := parseDuration(config.Config["serviceTimeout"]); err == nil {
 		scoreboard.Config.ServiceTimeout = stimeout
 
 	} else {
 		return configValidationError(fmt.Sprint("Failed to parse serviceTimeout from config file:", err))
 	}
 
+	if scoreboard.Config.TimeBetweenServiceChecks < minInterval {
+		return configValidationError(fmt.Sprintf("The 'serviceInterval:' field under 'config:' must be at "+
+			"least %v. Change 'minInterval:' to allow shorter intervals", minInterval))
+	}
+
+	if scoreboard.Config.ServiceTimeout <= 0 ||
+		scoreboard.Config.ServiceTimeout >= scoreboard.Config.TimeBetweenServiceChecks {
+		return configValidationError("The 'serviceTimeout:' field under 'config:' must be greater than 0 " +
+			"and shorter than 'serviceInterval:' so checks don't overlap")
+	}
+
+	// Determine the optional timeoutIncludesResolution option from the config file
+	if includesResolution := config.Config["timeoutIncludesResolution"]; includesResolution == "yes" {
+		scoreboard.Config.TimeoutIncludesResolution = true
+	} else if includesResolution != "" && includesResolution != "no" {
+		return configValidationError("The 'timeoutIncludesResolution:' field under 'config:' must be " +
+			"either 'yes' or 'no'")
+	}
+
+	// Determine the optional serviceOffset option from the config file
+	if serviceOffset, err := parseCheckOffset(config.Config["serviceOffset"], scoreboard.Config.TimeBetweenServiceChecks); err == nil {
+		scoreboard.Config.ServiceCheckOffset = serviceOffset
+	} else {
+		return configValidationError(fmt.Sprint("The 'serviceOffset:' field under 'config:' is invalid: ", err))
+	}
+
+	// Determine the optional tcpNoDelay option from the config file
+	if tcpNoDelay := config.Config["tcpNoDelay"]; tcpNoDelay == "" || tcpNoDelay == "yes" {
+		scoreboard.Config.TCPNoDelay = true
+	} else if tcpNoDelay != "no" {
+		return configValidationError("The 'tcpNoDelay:' field under 'config:' must be either 'yes' or 'no'")
+	}
+
+	// Determine the optional tcpKeepAlive option from the config file
+	switch tcpKeepAlive := config.Config["tcpKeepAlive"]; tcpKeepAlive {
+	case "":
+	case "off":
+		scoreboard.Config.TCPKeepAlive = -1
+	default:
+		if keepAlive, err := parseDuration(tcpKeepAlive); err == nil && keepAlive > 0 {
+			scoreboard.Config.TCPKeepAlive = keepAlive
+		} else {
+			return configValidationError("The 'tcpKeepAlive:' field under 'config:' must be either 'off' " +
+				"or a duration greater than 0s")
+		}
+	}
+
+	scoreboard.Config.RandomizeCheckOrder = config.Config["randomizeCheckOrder"] == "yes"
+	scoreboard.Config.RandomizeCheckTiming = config.Config["randomizeCheckTiming"] == "yes"
+
+	// Determine the optional commandKillGrace option from the config file
+	scoreboard.Config.CommandKillGrace = defaultCommandKillGrace
+	if scoreboard.Config.CommandKillGrace >= scoreboard.Config.ServiceTimeout {
+		scoreboard.Config.CommandKillGrace = scoreboard.Config.ServiceTimeout / 2
+	}
+
+	if killGrace := config.Config["commandKillGrace"]; killGrace != "" {
+		if commandKillGrace, err := parseDuration(killGrace); err == nil && commandKillGrace >= 0 &&
+			commandKillGrace < scoreboard.Config.ServiceTimeout {
+			scoreboard.Config.CommandKillGrace = commandKillGrace
+		} else {
+			return configValidationError("The 'commandKillGrace:' field under 'config:' must be a duration " +
+				"that is shorter than 'serviceTimeout:'")
+		}
+	}
+
+	// Determine the optional maxResponseBytes option from the config file
+	if maxResponseBytes, err := config.maxResponseBytes(); err == nil {
+		scoreboard.Config.MaxResponseBytes = maxResponseBytes
+	} else {
+		return err
+	}
+
+	// Determine the optional checkHelper option from the config file
+	if helper := config.Config["checkHelper"]; helper != "" {
+		command, err := splitCommand(helper)
+		if err == nil {
+			_, err = exec.LookPath(command[0])
+		}
+
+		if err != nil {
+			return configValidationError(fmt.Sprint("The 'checkHelper:' field under 'config:' must be a "+
+				"program that can be found: ", err))
+		}
+
+		scoreboard.Config.CheckHelper = helper
+		scoreboard.Config.checkHelper = newCheckHelper(command)
+	}
+
+	// Determine the optional latencyBuckets option from the config file
+	scoreboard.Config.LatencyBuckets = defaultLatencyBuckets
+	if buckets := config.Config["latencyBuckets"]; buckets != "" {
+		if latencyBuckets, err := parseLatencyBuckets(buckets); err == nil {
+			scoreboard.Config.LatencyBuckets = latencyBuckets
+		} else {
+			return configValidationError(fmt.Sprint("The 'latencyBuckets:' field under 'config:' must be a "+
+				"comma separated list of increasing durations: ", err))
+		}
+	}
+
+	// Determine the optional reuseConnections option from the config file
+	if reuseConnections := config.Config["reuseConnections"]; reuseConnections == "yes" {
+		scoreboard.Config.ReuseConnections = true
+		scoreboard.Config.connectionPool = newConnectionPool()
+	} else if reuseConnections != "" && reuseConnections != "no" {
+		return configValidationError("The 'reuseConnections:' field under 'config:' must be either 'yes' or 'no'")
+	}
+
+	// Determine the optional upThreshold and downThreshold options from the config file
+	scoreboard.Config.UpThreshold = 1
+	if threshold := config.Config["upThreshold"]; threshold != "" {
+		if upThreshold, err := strconv.Atoi(threshold); err == nil && upThreshold >= 1 {
+			scoreboard.Config.UpThreshold = upThreshold
+		} else {
+			return configValidationError("The 'upThreshold:' field under 'config:' must be a number of at least 1")
+		}
+	}
+
+	scoreboard.Config.DownThreshold = 1
+	if threshold := config.Config["downThreshold"]; threshold != "" {
+		if downThreshold, err := strconv.Atoi(threshold); err == nil && downThreshold >= 1 {
+			scoreboard.Config.DownThreshold = downThreshold
+		} else {
+			return configValidationError("The 'downThreshold:' field under 'config:' must be a number of at least 1")
+		}
+	}
+
 	if configDefaultServiceState := config.Config["defaultState"]; configDefaultServiceState != "" {
 		if configDefaultServiceState == "up" {
 			scoreboard.Config.DefaultServiceState = true
@@ -201,6 +895,16 @@ func parseConfigToScoreboard(config *YamlConfig, scoreboard *State) error {
 		return configValidationError(fmt.Sprint("Failed to parse defaultState from 'config:' section!"))
 	}
 
+	// Determine the optional warmUpGrace option from the config file
+	if grace := config.Config["warmUpGrace"]; grace != "" {
+		if warmUpGrace, err := parseDuration(grace); err == nil && warmUpGrace >= 0 {
+			scoreboard.Config.WarmUpGrace = warmUpGrace
+		} else {
+			return configValidationError("The 'warmUpGrace:' field under 'config:' must be a duration " +
+				"of at least 0s")
+		}
+	}
+
 	if configCompetitionName := config.Config["competitionName"]; configCompetitionName != "" {
 		scoreboard.Name = configCompetitionName
 	} else {
@@ -216,8 +920,140 @@ func parseConfigToScoreboard(config *YamlConfig, scoreboard *State) error {
 		}
 	}
 
+	if staticDirectory := config.Config["staticDirectory"]; staticDirectory != "" {
+		if info, err := os.Stat(staticDirectory); err == nil && info.IsDir() {
+			scoreboard.Config.StaticDirectory = staticDirectory
+		} else {
+			return configValidationError("The 'staticDirectory:' field under 'config:' must be an existing directory")
+		}
+	}
+
+	// Determine the optional uptimeHalfLife option from the config file
+	if halfLife := config.Config["uptimeHalfLife"]; halfLife != "" {
+		if uptimeHalfLife, err := parseDuration(halfLife); err == nil && uptimeHalfLife > 0 {
+			scoreboard.Config.UptimeHalfLife = uptimeHalfLife
+		} else {
+			return configValidationError("The 'uptimeHalfLife:' field under 'config:' must be a duration " +
+				"greater than 0s")
+		}
+	}
+
+	// Determine the optional maxGainPerInterval option from the config file
+	if maxGain := config.Config["maxGainPerInterval"]; maxGain != "" {
+		if maxGainPerInterval, err := strconv.ParseInt(maxGain, 10, 64); err == nil && maxGainPerInterval > 0 {
+			scoreboard.Config.MaxGainPerInterval = maxGainPerInterval
+		} else {
+			return configValidationError("The 'maxGainPerInterval:' field under 'config:' must be a positive number")
+		}
+	}
+
+	switch sortBy := config.Config["sortBy"]; sortBy {
+	case "", "config":
+		scoreboard.Config.SortBy = "config"
+	case "score", "uptime", "name":
+		scoreboard.Config.SortBy = sortBy
+	case "weighted":
+		if scoreboard.Config.UptimeHalfLife == 0 {
+			return configValidationError("The 'uptimeHalfLife:' field under 'config:' must be set to " +
+				"sort hosts by their weighted uptime")
+		}
+
+		scoreboard.Config.SortBy = sortBy
+	default:
+		return configValidationError(fmt.Sprintf("Unknown sortBy '%v' in 'config:'. "+
+			"Must be one of 'config', 'score', 'uptime', 'weighted', or 'name'", sortBy))
+	}
+
+	if size := config.Config["pageSize"]; size != "" {
+		if pageSize, err := strconv.Atoi(size); err == nil && pageSize >= 0 {
+			scoreboard.Config.PageSize = pageSize
+		} else {
+			return configValidationError("The 'pageSize:' field under 'config:' must be a number of at least 0")
+		}
+	}
+
+	// Determine the optional groupByCategory option from the config file
+	if groupByCategory := config.Config["groupByCategory"]; groupByCategory == "yes" {
+		scoreboard.Config.GroupByCategory = true
+	} else if groupByCategory != "" && groupByCategory != "no" {
+		return configValidationError("The 'groupByCategory:' field under 'config:' must be either 'yes' or 'no'")
+	}
+
+	scoreboard.Config.HistoryLimit = defaultHistoryLimit
+	if limit := config.Config["historyLimit"]; limit != "" {
+		if historyLimit, err := strconv.Atoi(limit); err == nil && historyLimit >= 1 && historyLimit <= maxHistoryLimit {
+			scoreboard.Config.HistoryLimit = historyLimit
+		} else {
+			return configValidationError(fmt.Sprintf("The 'historyLimit:' field under 'config:' must be a "+
+				"number from 1 to %v", maxHistoryLimit))
+		}
+	}
+
+	scoreboard.Config.ExcludeHostDownFromService = config.Config["excludeHostDownFromService"] == "yes"
+	if scoreboard.Config.ExcludeHostDownFromService && !scoreboard.Config.PingHosts {
+		return configValidationError("The 'excludeHostDownFromService:' field under 'config:' can only be " +
+			"used when 'pingHosts:' is set to yes")
+	}
+
+	scoreboard.Config.AccessibleColors = config.Config["accessibleColors"] == "yes"
+
+	scoreboard.Config.UnavailableBeforeStart = config.Config["unavailableBeforeStart"] == "yes"
+
+	scoreboard.Config.UpColor = defaultUpColor
+	if upColor := config.Config["upColor"]; upColor != "" {
+		if cssColor.MatchString(upColor) {
+			scoreboard.Config.UpColor = upColor
+		} else {
+			return configValidationError("The 'upColor:' field under 'config:' must be a CSS color " +
+				"such as 'green' or '#00ff00'")
+		}
+	}
+
+	scoreboard.Config.DownColor = defaultDownColor
+	if downColor := config.Config["downColor"]; downColor != "" {
+		if cssColor.MatchString(downColor) {
+			scoreboard.Config.DownColor = downColor
+		} else {
+			return configValidationError("The 'downColor:' field under 'config:' must be a CSS color " +
+				"such as 'red' or '#ff0000'")
+		}
+	}
+
+	scoreboard.Config.BackgroundColor = defaultBackgroundColor
+	if bgColor := config.Config["bgColor"]; bgColor != "" {
+		if cssColor.MatchString(bgColor) {
+			scoreboard.Config.BackgroundColor = bgColor
+		} else {
+			return configValidationError("The 'bgColor:' field under 'config:' must be a CSS color " +
+				"such as 'navy' or '#133f7c'")
+		}
+	}
+
+	// Determine the optional uptimeGoodPct and uptimeWarnPct options from the config file
+	scoreboard.Config.UptimeGoodPercent = defaultUptimeGoodPercent
+	if percent := config.Config["uptimeGoodPct"]; percent != "" {
+		if goodPercent, err := strconv.ParseFloat(percent, 64); err == nil && goodPercent >= 0 && goodPercent <= 100 {
+			scoreboard.Config.UptimeGoodPercent = goodPercent
+		} else {
+			return configValidationError("The 'uptimeGoodPct:' field under 'config:' must be a number from 0 to 100")
+		}
+	}
+
+	scoreboard.Config.UptimeWarnPercent = defaultUptimeWarnPercent
+	if percent := config.Config["uptimeWarnPct"]; percent != "" {
+		if warnPercent, err := strconv.ParseFloat(percent, 64); err == nil && warnPercent >= 0 && warnPercent <= 100 {
+			scoreboard.Config.UptimeWarnPercent = warnPercent
+		} else {
+			return configValidationError("The 'uptimeWarnPct:' field under 'config:' must be a number from 0 to 100")
+		}
+	}
+
+	if scoreboard.Config.UptimeWarnPercent > scoreboard.Config.UptimeGoodPercent {
+		return configValidationError("The 'uptimeWarnPct:' field under 'config:' can't be more than 'uptimeGoodPct:'")
+	}
+
 	if duration := config.Config["competitionDuration"]; duration != "" {
-		if gameDuration, err := time.ParseDuration(duration); err == nil {
+		if gameDuration, err := parseDuration(duration); err == nil {
 			scoreboard.Config.CompetitionDuration = gameDuration
 		} else {
 			return configValidationError(fmt.Sprint("Failed to parse duration:", err))
@@ -226,12 +1062,128 @@ func parseConfigToScoreboard(config *YamlConfig, scoreboard *State) error {
 		return configValidationError(fmt.Sprint("Failed to parse duration from 'config:'"))
 	}
 
+	// Determine the optional startTime option from the config file
+	if startTime := config.Config["startTime"]; startTime != "" {
+		if scheduledStart, err := time.Parse(time.RFC3339, startTime); err == nil {
+			scoreboard.Config.ScheduledStart = scheduledStart
+		} else {
+			return configValidationError(fmt.Sprintf("The 'startTime:' field under 'config:' must be a time "+
+				"such as '2019-10-05T09:00:00-05:00', not %q", startTime))
+		}
+	}
+
+	if grace := config.Config["shutdownAfterEnd"]; grace != "" {
+		if shutdownAfterEnd, err := parseDuration(grace); err == nil {
+			scoreboard.Config.ShutdownAfterEnd = shutdownAfterEnd
+		} else {
+			return configValidationError(fmt.Sprint("Failed to parse shutdownAfterEnd:", err))
+		}
+	}
+
+	if snapshotOnEnd := config.Config["snapshotOnEnd"]; snapshotOnEnd == "yes" {
+		scoreboard.Config.SnapshotOnEnd = true
+	} else if snapshotOnEnd != "" && snapshotOnEnd != "no" {
+		return configValidationError("The 'snapshotOnEnd:' field under 'config:' must be either 'yes' or 'no'")
+	}
+
+	scoreboard.Config.SnapshotFile = defaultSnapshotFile
+	if snapshotFile := config.Config["snapshotFile"]; snapshotFile != "" {
+		scoreboard.Config.SnapshotFile = snapshotFile
+	}
+
+	scoreboard.Config.ResultsJSONFile = config.Config["resultsJSONFile"]
+	scoreboard.Config.TransitionLog = config.Config["transitionLog"]
+
+	scoreboard.Config.InfluxEndpoint = config.Config["influxEndpoint"]
+
+	if natsURL := config.Config["natsURL"]; natsURL != "" {
+		if parsedURL, err := url.Parse(natsURL); err != nil || parsedURL.Scheme != "nats" || parsedURL.Hostname() == "" {
+			return configValidationError("The 'natsURL:' field under 'config:' must be a URL such as " +
+				"'nats://127.0.0.1:4222'")
+		}
+
+		scoreboard.Config.NATSURL = natsURL
+		scoreboard.Config.NATSSubject = config.Config["natsSubject"]
+		if scoreboard.Config.NATSSubject == "" {
+			scoreboard.Config.NATSSubject = defaultNATSSubject
+		} else if strings.ContainsAny(scoreboard.Config.NATSSubject, " \t\r\n") {
+			return configValidationError("The 'natsSubject:' field under 'config:' can't contain whitespace")
+		}
+	}
+
+	scoreboard.Config.OnStateChange = strings.TrimSpace(config.Config["onStateChange"])
+	if scoreboard.Config.OnStateChange != "" {
+		if _, err := splitCommand(scoreboard.Config.OnStateChange); err != nil {
+			return configValidationError(fmt.Sprint("The 'onStateChange:' field under 'config:' is invalid: ", err))
+		}
+	}
+
+	scoreboard.Config.OnStateChangeTimeout = defaultOnStateChangeTimeout
+	if hookTimeout := config.Config["onStateChangeTimeout"]; hookTimeout != "" {
+		if onStateChangeTimeout, err := parseDuration(hookTimeout); err == nil && onStateChangeTimeout > 0 {
+			scoreboard.Config.OnStateChangeTimeout = onStateChangeTimeout
+		} else {
+			return configValidationError("The 'onStateChangeTimeout:' field under 'config:' must be a " +
+				"duration greater than 0")
+		}
+	}
+
+	if interval := config.Config["scoreSnapshotInterval"]; interval != "" {
+		if snapshotInterval, err := parseDuration(interval); err == nil {
+			scoreboard.Config.ScoreSnapshotInterval = snapshotInterval
+		} else {
+			return configValidationError(fmt.Sprint("Failed to parse scoreSnapshotInterval:", err))
+		}
+	}
+
 	if listenAddr := config.Config["listenAddress"]; listenAddr != "" {
+		if _, err := listenPort(listenAddr); err != nil {
+			return configValidationError(fmt.Sprintf("The 'listenAddress:' field under 'config:' must be "+
+				"an address and port such as ':80' or '127.0.0.1:8080': %v", err))
+		}
+
 		scoreboard.Config.ListenAddress = listenAddr
 	} else {
 		return configValidationError(fmt.Sprint("Failed to parse listenAddress from 'config:'"))
 	}
 
+	if metricsListenAddr := config.Config["metricsListenAddress"]; metricsListenAddr != "" {
+		if _, err := listenPort(metricsListenAddr); err != nil {
+			return configValidationError(fmt.Sprintf("The 'metricsListenAddress:' field under 'config:' "+
+				"must be an address and port such as '127.0.0.1:9100': %v", err))
+		}
+
+		scoreboard.Config.MetricsListenAddress = metricsListenAddr
+	}
+
+	// Determine the optional webserver timeouts from the config file
+	scoreboard.Config.HTTPReadTimeout = defaultHTTPReadTimeout
+	if timeout := config.Config["httpReadTimeout"]; timeout != "" {
+		if httpReadTimeout, err := parseDuration(timeout); err == nil && httpReadTimeout > 0 {
+			scoreboard.Config.HTTPReadTimeout = httpReadTimeout
+		} else {
+			return configValidationError("The 'httpReadTimeout:' field under 'config:' must be a positive duration")
+		}
+	}
+
+	scoreboard.Config.HTTPWriteTimeout = defaultHTTPWriteTimeout
+	if timeout := config.Config["httpWriteTimeout"]; timeout != "" {
+		if httpWriteTimeout, err := parseDuration(timeout); err == nil && httpWriteTimeout > 0 {
+			scoreboard.Config.HTTPWriteTimeout = httpWriteTimeout
+		} else {
+			return configValidationError("The 'httpWriteTimeout:' field under 'config:' must be a positive duration")
+		}
+	}
+
+	scoreboard.Config.HTTPIdleTimeout = defaultHTTPIdleTimeout
+	if timeout := config.Config["httpIdleTimeout"]; timeout != "" {
+		if httpIdleTimeout, err := parseDuration(timeout); err == nil && httpIdleTimeout > 0 {
+			scoreboard.Config.HTTPIdleTimeout = httpIdleTimeout
+		} else {
+			return configValidationError("The 'httpIdleTimeout:' field under 'config:' must be a positive duration")
+		}
+	}
+
 	if mgmntUsrnm := config.Config["managementUsername"]; mgmntUsrnm != "" {
 		scoreboard.Config.AdminName = mgmntUsrnm
 	} else {
@@ -244,6 +1196,118 @@ func parseConfigToScoreboard(config *YamlConfig, scoreboard *State) error {
 		return configValidationError("Failed to parse managementUsername from 'config:'")
 	}
 
+	scoreboard.Config.AdminLoginMessage = config.Config["adminLoginMessage"]
+
+	for hostIndex := range config.Hosts {
+		host := &config.Hosts[hostIndex]
+
+		for serviceIndex := range host.Services {
+			service := &host.Services[serviceIndex]
+
+			// Give every service a stable identifier to match updates to it
+			service.id = fmt.Sprintf("%v|%v|%v", host.IP, serviceIndex, service.Name)
+
+			// Interpret escapes in commands the same way for every protocol
+			service.Command = interpretEscapes(service.Command)
+			for stepIndex := range service.Script {
+				service.Script[stepIndex].Send = interpretEscapes(service.Script[stepIndex].Send)
+			}
+			for checkIndex := range service.Commands {
+				service.Commands[checkIndex].Command = interpretEscapes(service.Commands[checkIndex].Command)
+			}
+
+			if service.ActiveHours != "" {
+				service.activeFrom, service.activeUntil, _ = parseActiveHours(service.ActiveHours)
+			}
+		}
+	}
+
+	if clientCA := config.Config["adminClientCA"]; clientCA != "" {
+		caBytes, err := ioutil.ReadFile(clientCA)
+		if err != nil {
+			return configValidationError(fmt.Sprint("Failed to read adminClientCA:", err))
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBytes) {
+			return configValidationError("Failed to parse any PEM certificates from adminClientCA")
+		}
+
+		if config.Config["adminCertificate"] == "" || config.Config["adminKey"] == "" {
+			return configValidationError("You must define the 'adminCertificate:' and 'adminKey:' " +
+				"fields under 'config:' when 'adminClientCA:' is set")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(config.Config["adminCertificate"], config.Config["adminKey"])
+		if err != nil {
+			return configValidationError(fmt.Sprint("Failed to load adminCertificate and adminKey:", err))
+		}
+
+		if adminListenAddr := config.Config["adminListenAddress"]; adminListenAddr != "" {
+			if _, err := listenPort(adminListenAddr); err != nil {
+				return configValidationError(fmt.Sprintf("The 'adminListenAddress:' field under 'config:' "+
+					"must be an address and port such as ':8443': %v", err))
+			}
+
+			scoreboard.Config.AdminListenAddress = adminListenAddr
+		} else {
+			return configValidationError("You must define the 'adminListenAddress:' field under " +
+				"'config:' when 'adminClientCA:' is set")
+		}
+
+		scoreboard.Config.AdminTLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			ClientCAs:    clientCAs,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		}
+	}
+
+	// Determine the optional client IP restrictions from the config file
+	var err error
+	if scoreboard.Config.ScoreboardAllowNets, err = parseCIDRList(config.Config["scoreboardAllowCIDRs"]); err != nil {
+		return configValidationError(fmt.Sprint("Failed to parse scoreboardAllowCIDRs: ", err))
+	}
+
+	if scoreboard.Config.AdminAllowNets, err = parseCIDRList(config.Config["adminAllowCIDRs"]); err != nil {
+		return configValidationError(fmt.Sprint("Failed to parse adminAllowCIDRs: ", err))
+	}
+
+	if scoreboard.Config.TrustedProxyNets, err = parseCIDRList(config.Config["trustedProxyCIDRs"]); err != nil {
+		return configValidationError(fmt.Sprint("Failed to parse trustedProxyCIDRs: ", err))
+	}
+
+	scoreboard.Config.IngestToken = config.Config["ingestToken"]
+
+	if probeTarget := config.Config["probeTarget"]; probeTarget != "" {
+		if !strings.HasPrefix(probeTarget, "http://") && !strings.HasPrefix(probeTarget, "https://") {
+			return configValidationError("The 'probeTarget:' field under 'config:' must be a http:// " +
+				"or https:// URL")
+		}
+
+		if scoreboard.Config.IngestToken == "" {
+			return configValidationError("You must define the 'ingestToken:' field under 'config:' " +
+				"when 'probeTarget:' is set")
+		}
+
+		scoreboard.Config.ProbeTarget = probeTarget
+		scoreboard.Config.ProbeName = config.Config["probeName"]
+
+		if scoreboard.Config.ProbeName == "" {
+			if hostname, err := os.Hostname(); err == nil {
+				scoreboard.Config.ProbeName = hostname
+			} else {
+				return configValidationError("You must define the 'probeName:' field under 'config:' " +
+					"when 'probeTarget:' is set")
+			}
+		}
+
+		// The central instance rejects results under the name that replayed transitions use
+		if scoreboard.Config.ProbeName == replayProbe {
+			return configValidationError(fmt.Sprintf("The 'probeName:' field under 'config:' can't be '%v'",
+				replayProbe))
+		}
+	}
+
 	scoreboard.Hosts = config.Hosts
 
 	return nil
@@ -0,0 +1,234 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testConfig returns the smallest config that passes validation
+func testConfig() *YamlConfig {
+	return &YamlConfig{
+		Hosts: []Host{{Name: "web", IP: "127.0.0.1",
+			Services: []Service{{Name: "ssh", Port: "22", Protocol: "tcp"}}}},
+		Config: map[string]string{
+			"version":             "2",
+			"competitionName":     "test",
+			"competitionDuration": "1h",
+			"serviceInterval":     "10s",
+			"serviceTimeout":      "5s",
+			"pingHosts":           "no",
+			"defaultState":        "up",
+			"listenAddress":       ":8080",
+			"managementUsername":  "admin",
+			"managementPassword":  "password",
+		},
+	}
+}
+
+func TestValidateConfigDuplicateService(t *testing.T) {
+	config := testConfig()
+	if err := config.validateConfig(); err != nil {
+		t.Fatalf("validateConfig() of the base config = %v, want nil", err)
+	}
+
+	config.Hosts[0].Services = append(config.Hosts[0].Services,
+		Service{Name: "ssh", Port: "2222", Protocol: "tcp"})
+
+	err := config.validateConfig()
+	if err == nil {
+		t.Fatal("validateConfig() accepted two services named ssh on the same host")
+	}
+
+	if _, ok := err.(configValidationError); !ok || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("validateConfig() = %v, want a configValidationError about the duplicate service", err)
+	}
+
+	// The same service name on different hosts is fine
+	config = testConfig()
+	config.Hosts = append(config.Hosts, Host{Name: "db", IP: "127.0.0.2",
+		Services: []Service{{Name: "ssh", Port: "22", Protocol: "tcp"}}})
+
+	if err := config.validateConfig(); err != nil {
+		t.Errorf("validateConfig() of ssh on two hosts = %v, want nil", err)
+	}
+}
+
+func TestValidateConfigAddresses(t *testing.T) {
+	tests := []struct {
+		ip, ipv6 string
+		want     string
+	}{
+		{"127.0.0.1", "", ""},
+		{"web.example.com", "", ""},
+		{"127.0.0.1", "::1", ""},
+		{"10.0.0.256", "::1", "ip:"},
+		{"127.0.0.1", "127.0.0.2", "ipv6:"},
+		{"127.0.0.1", "web.example.com", "ipv6:"},
+	}
+
+	for _, test := range tests {
+		config := testConfig()
+		config.Hosts[0].IP = test.ip
+		config.Hosts[0].IPv6 = test.ipv6
+
+		err := config.validateConfig()
+		if test.want == "" && err != nil {
+			t.Errorf("validateConfig() of ip %q ipv6 %q = %v, want nil", test.ip, test.ipv6, err)
+		} else if _, ok := err.(configValidationError); test.want != "" &&
+			(!ok || !strings.Contains(err.Error(), "The "+test.want)) {
+			t.Errorf("validateConfig() of ip %q ipv6 %q = %v, want a configValidationError about %v",
+				test.ip, test.ipv6, err, test.want)
+		}
+	}
+}
+
+func TestTimeoutShorterThanInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     string
+	}{
+		{"service timeout equals interval",
+			map[string]string{"serviceInterval": "10s", "serviceTimeout": "10s"}, "serviceTimeout"},
+		{"service timeout exceeds interval",
+			map[string]string{"serviceInterval": "10s", "serviceTimeout": "11s"}, "serviceTimeout"},
+		{"service timeout just under interval",
+			map[string]string{"serviceInterval": "10s", "serviceTimeout": "9999ms"}, ""},
+		{"ping timeout equals interval",
+			map[string]string{"pingHosts": "yes", "pingInterval": "10s", "pingTimeout": "10s"}, "pingTimeout"},
+		{"ping timeout exceeds interval",
+			map[string]string{"pingHosts": "yes", "pingInterval": "10s", "pingTimeout": "11s"}, "pingTimeout"},
+		{"ping timeout just under interval",
+			map[string]string{"pingHosts": "yes", "pingInterval": "10s", "pingTimeout": "9999ms"}, ""},
+		{"ping retries within interval",
+			map[string]string{"pingHosts": "yes", "pingInterval": "10s", "pingTimeout": "3s",
+				"pingRetries": "2"}, ""},
+		{"ping retries exceed interval",
+			map[string]string{"pingHosts": "yes", "pingInterval": "10s", "pingTimeout": "4s",
+				"pingRetries": "2"}, "pingRetries"},
+	}
+
+	for _, test := range tests {
+		config := testConfig()
+		for key, value := range test.settings {
+			config.Config[key] = value
+		}
+
+		scoreboard := NewScoreboard()
+		err := parseConfigToScoreboard(config, &scoreboard)
+
+		if test.want == "" && err != nil {
+			t.Errorf("%v: parseConfigToScoreboard() = %v, want nil", test.name, err)
+		} else if test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("%v: parseConfigToScoreboard() = %v, want an error about %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestMinInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     string
+	}{
+		{"service interval just under the default floor",
+			map[string]string{"serviceInterval": "4999ms", "serviceTimeout": "1s"}, "'serviceInterval:'"},
+		{"service interval at the default floor",
+			map[string]string{"serviceInterval": "5s", "serviceTimeout": "1s"}, ""},
+		{"service interval under a lowered floor",
+			map[string]string{"minInterval": "1s", "serviceInterval": "2s", "serviceTimeout": "1s"}, ""},
+		{"service interval under a raised floor",
+			map[string]string{"minInterval": "30s", "serviceInterval": "10s"}, "'serviceInterval:'"},
+		{"ping interval just under the default floor",
+			map[string]string{"pingHosts": "yes", "pingInterval": "4999ms", "pingTimeout": "1s"}, "'pingInterval:'"},
+		{"ping interval at the default floor",
+			map[string]string{"pingHosts": "yes", "pingInterval": "5s", "pingTimeout": "1s"}, ""},
+		{"ping interval under a lowered floor",
+			map[string]string{"minInterval": "1s", "pingHosts": "yes", "pingInterval": "2s", "pingTimeout": "1s"}, ""},
+	}
+
+	for _, test := range tests {
+		config := testConfig()
+		for key, value := range test.settings {
+			config.Config[key] = value
+		}
+
+		scoreboard := NewScoreboard()
+		err := parseConfigToScoreboard(config, &scoreboard)
+
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%v: parseConfigToScoreboard() = %v, want nil", test.name, err)
+			}
+			continue
+		}
+
+		if _, ok := err.(configValidationError); !ok || !strings.Contains(err.Error(), test.want) ||
+			!strings.Contains(err.Error(), "minInterval") {
+			t.Errorf("%v: parseConfigToScoreboard() = %v, want a configValidationError about %v",
+				test.name, err, test.want)
+		}
+	}
+}
+
+func TestPingRetriesCountAddresses(t *testing.T) {
+	config := testConfig()
+	config.Hosts[0].IPv6 = "::1"
+	config.Hosts[0].BackupIP = "127.0.0.2"
+	config.Config["pingHosts"] = "yes"
+	config.Config["pingInterval"] = "10s"
+	config.Config["pingTimeout"] = "3s"
+
+	// Three addresses pinged once each take 9s
+	scoreboard := NewScoreboard()
+	if err := parseConfigToScoreboard(config, &scoreboard); err != nil {
+		t.Errorf("parseConfigToScoreboard() of three addresses = %v, want nil", err)
+	}
+
+	// Looking up a hostname takes another 3s
+	config.Hosts[0].IP = "web.example.com"
+	scoreboard = NewScoreboard()
+	if err := parseConfigToScoreboard(config, &scoreboard); err == nil || !strings.Contains(err.Error(), "pingRetries") {
+		t.Errorf("parseConfigToScoreboard() of a hostname and two addresses = %v, want an error about "+
+			"pingRetries", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		duration string
+		want     time.Duration
+	}{
+		{"90m", 90 * time.Minute},
+		{"2d", 48 * time.Hour},
+		{"1d6h", 30 * time.Hour},
+		{"1d12h30m", 36*time.Hour + 30*time.Minute},
+	}
+
+	for _, test := range tests {
+		if got, err := parseDuration(test.duration); err != nil || got != test.want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v", test.duration, got, err, test.want)
+		}
+	}
+
+	for _, duration := range []string{"", "d", "xd", "1.5d", "1h2d", "1d-6h"} {
+		if got, err := parseDuration(duration); err == nil {
+			t.Errorf("parseDuration(%q) = %v, want an error", duration, got)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// decayedTimes returns the uptime and the total scored time of the Service in seconds as
+// of referenceTime, with every moment weighted by how long ago it was. A moment counts
+// half as much for every decayHalfLife that has passed since, so recent time matters
+// more than early time. Time doesn't pass for the weights while the Service is paused.
+func (service *Service) decayedTimes(referenceTime time.Time) (float64, float64) {
+	uptime, total := service.decayedUptime, service.decayedTotal
+
+	elapsed := referenceTime.Sub(service.previousUpdateTime).Seconds()
+	if service.decayHalfLife <= 0 || service.paused || elapsed <= 0 {
+		return uptime, total
+	}
+
+	// The weight of the time since the last update is the integral of the decay over it
+	rate := math.Ln2 / service.decayHalfLife.Seconds()
+	decay := math.Exp(-rate * elapsed)
+	weight := (1 - decay) / rate
+
+	uptime *= decay
+	total = total*decay + weight
+	if service.isUp {
+		uptime += weight
+	}
+
+	return uptime, total
+}
+
+// WeightedUptimePercent returns the percentage of the scored time that a service has been up,
+// with recent time weighted more than early time according to UptimeHalfLife. Once the
+// competition has ended, the weights are as of StopTime. This is zero if UptimeHalfLife
+// isn't set.
+func (sbd *State) WeightedUptimePercent(service *Service) float64 {
+	return decayedPercent(service.decayedTimes(sbd.referenceTime()))
+}
+
+// HostWeightedUptimePercent returns the WeightedUptimePercent of the scored services of a
+// host, combined.
+func (sbd *State) HostWeightedUptimePercent(host *Host) float64 {
+	var uptime, total float64
+
+	referenceTime := sbd.referenceTime()
+	for serviceIndex := range host.Services {
+		if !host.Services[serviceIndex].IsScored() {
+			continue
+		}
+
+		serviceUptime, serviceTotal := host.Services[serviceIndex].decayedTimes(referenceTime)
+		uptime += serviceUptime
+		total += serviceTotal
+	}
+
+	return decayedPercent(uptime, total)
+}
+
+// decayedPercent returns the percentage of the decayed total time that was uptime
+func decayedPercent(uptime, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+
+	return uptime / total * 100
+}
+
+// trackedPercent returns a pointer to a weighted uptime percent for the JSON API, or nil
+// if UptimeHalfLife isn't set so that the untracked percent is left out.
+func (sbd *State) trackedPercent(percent float64) *float64 {
+	if sbd.Config.UptimeHalfLife <= 0 {
+		return nil
+	}
+
+	return &percent
+}
+
+// referenceTime returns the time that uptimes are measured up to, which is StopTime once
+// the competition has ended, StartTime before the competition has started, and now otherwise.
+func (sbd *State) referenceTime() time.Time {
+	if sbd.Config.CompetitionEnded {
+		return sbd.Config.StopTime
+	} else if now := time.Now(); now.After(sbd.Config.StartTime) {
+		return now
+	}
+
+	return sbd.Config.StartTime
+}
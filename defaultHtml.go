@@ -25,7 +25,7 @@ body {
   display: flex;
   font-family: arial, serif;
   justify-content: center;
-  background-color: #133f7c;
+  background-color: {{ .BackgroundColor }};
   height: 100%;
   margin: 0;
   padding: 0;
@@ -46,7 +46,7 @@ h2 {
   flex-direction: column;
   background-color: white;
   border-radius: 2vmin;
-  box-shadow: 0 0 1vmin #133f7c;
+  box-shadow: 0 0 1vmin {{ .BackgroundColor }};
 }
 .footer {
   display: flex;
@@ -81,18 +81,77 @@ h2 {
   padding: 0.5vh 1vw;
 }
 .up {
-  background-color: green;
+  background-color: {{ .UpColor }};
 }
 .down {
-  background-color: red;
+  background-color: {{ .DownColor }};
+}
+.accessible .up {
+  background-color: #0072b2;
+  color: white;
+}
+.accessible .down {
+  background-color: #e69f00;
+}
+.ended {
+  color: {{ .DownColor }};
+}
+.pages {
+  margin: 1vh 0;
+  font-size: 1.2em;
+}
+.uptimeGood {
+  background-color: #9be39b;
+}
+.uptimeWarn {
+  background-color: #f5e27a;
+}
+.uptimeBad {
+  background-color: #f29b9b;
+}
+.accessible .uptimeGood {
+  background-color: #56b4e9;
+}
+.accessible .uptimeWarn {
+  background-color: #f0e442;
+}
+.accessible .uptimeBad {
+  background-color: #e69f00;
 }
 		</style>
 		<meta http-equiv="refresh" content="5" />
 	</head>
-	<body>
+	<body{{ if .AccessibleColors }} class="accessible"{{ end }}>
 		<div class="serviceTable">
-		<h2>{{ .Title }} Scoreboard</h2>
+		<h2>{{ .Title }} Scoreboard</h2>{{ if eq .Phase "pre" }}
+		<h2>The competition has not started yet</h2>
+		<h2>Starts In: {{ FormatDuration .StartsIn }}</h2>{{ else }}{{ if eq .Phase "ended" }}
+		<h2 class="ended">COMPETITION ENDED</h2>
+		<h2>Final Standings</h2>{{ else }}
 		<h2>Time Left: {{ FormatDuration .TimeLeft }}</h2>
+		<h3>Elapsed: {{ FormatDuration Elapsed }} | Current Time: {{ Now }}</h3>{{ if .Initializing }}
+		<h3>Initializing: checking every service for the first time</h3>{{ end }}{{ end }}{{ $pingHosts := .PingHosts }}{{ $accessible := .AccessibleColors }}{{ if .GroupByCategory }}{{ range .Categories }}
+		<h2>{{ .Name }}: {{ .Up }} / {{ .Total }} Up</h2>
+		<table>
+			<tr>
+				<th>Host</th>
+				<th>Service</th>
+				<th>State</th>
+				<th>Uptime</th>
+				<th>Downtime</th>
+			</tr>{{ range .Services }}{{ $host := .Host }}{{ $service := .Service }}
+			<tr>
+				<td>{{ $host.Name }}{{ if $host.IsBackup $host.ActiveAddress }} (backup){{ end }}</td>
+				<td>{{ $service.Label }}{{ if $host.IsBackup $service.ActiveAddress }} (backup){{ end }}{{ if not $service.IsScored }} (not scored){{ end }}</td>{{ if not $service.IsActive }}
+				<td>Inactive</td>{{ else if $pingHosts }}{{ if and $host.IsUp $service.IsUp }}
+				<td class="up">{{ if $accessible }}&#10003; {{ end }}Online</td>{{ else }}
+				<td class="down">{{ if $accessible }}&#10007; {{ end }}Offline</td>{{ end }}{{ else }}{{ if $service.IsUp }}
+				<td class="up">{{ if $accessible }}&#10003; {{ end }}Online</td>{{ else }}
+				<td class="down">{{ if $accessible }}&#10007; {{ end }}Offline</td>{{ end }}{{ end }}
+				<td class="{{ UptimeClass $service }}">{{ FormatDuration (Uptime $service) }}</td>
+				<td>{{ FormatDuration (Downtime $service) }}</td>
+			</tr>{{ end }}
+		</table>{{ end }}{{ else }}
 		<table>
 			<tr>
 				<th>Host</th>
@@ -100,24 +159,99 @@ h2 {
 				<th>State</th>
 				<th>Uptime</th>
 				<th>Downtime</th>
-			</tr>{{ $pingHosts := .PingHosts }}{{ range $hostIndex, $host := .Hosts }}{{ range $serviceIndex, $service := $host.Services }} 
+			</tr>{{ range $hostIndex, $host := .Hosts }}{{ range $serviceIndex, $service := $host.Services }} 
 			<tr>
-				<td>{{ $host.Name }}</td>
-				<td>{{ $service.Name }}</td>{{ if $pingHosts }}{{ if and $host.IsUp $service.IsUp }}
-				<td class="up">Online</td>{{ else }}
-				<td class="down">Offline</td>{{ end }}{{ else }}{{ if $service.IsUp }}
-				<td class="up">Online</td>{{ else }}
-				<td class="down">Offline</td>{{ end }}{{ end }}
-				<td>{{ FormatDuration (Uptime $service) }}</td>
+				<td>{{ $host.Name }}{{ if $host.IsBackup $host.ActiveAddress }} (backup){{ end }}</td>
+				<td>{{ $service.Label }}{{ if $host.IsBackup $service.ActiveAddress }} (backup){{ end }}{{ if not $service.IsScored }} (not scored){{ end }}</td>{{ if not $service.IsActive }}
+				<td>Inactive</td>{{ else if $pingHosts }}{{ if and $host.IsUp $service.IsUp }}
+				<td class="up">{{ if $accessible }}&#10003; {{ end }}Online</td>{{ else }}
+				<td class="down">{{ if $accessible }}&#10007; {{ end }}Offline</td>{{ end }}{{ else }}{{ if $service.IsUp }}
+				<td class="up">{{ if $accessible }}&#10003; {{ end }}Online</td>{{ else }}
+				<td class="down">{{ if $accessible }}&#10007; {{ end }}Offline</td>{{ end }}{{ end }}
+				<td class="{{ UptimeClass $service }}">{{ FormatDuration (Uptime $service) }}</td>
 				<td>{{ FormatDuration (Downtime $service) }}</td>
 			</tr>{{ end }}{{ end }}
-		</table>
+		</table>{{ end }}{{ $hasBonuses := false }}{{ range .Hosts }}{{ if .BonusPoints }}{{ $hasBonuses = true }}{{ end }}{{ end }}{{ if $hasBonuses }}
+		<h2>Bonus Points</h2>
+		<table>
+			<tr>
+				<th>Host</th>
+				<th>Bonus</th>
+			</tr>{{ range .Hosts }}{{ if .BonusPoints }}
+			<tr>
+				<td>{{ .Name }}</td>
+				<td>{{ .BonusPoints }}</td>
+			</tr>{{ end }}{{ end }}
+		</table>{{ end }}{{ if .Annotations }}
+		<h2>Timeline Notes</h2>
+		<table>
+			<tr>
+				<th>Window</th>
+				<th>Note</th>
+			</tr>{{ range .Annotations }}
+			<tr>
+				<td>{{ .Start.Format "15:04" }} - {{ .End.Format "15:04" }}</td>
+				<td>{{ .Note }}{{ if .Excused }} (excused){{ end }}</td>
+			</tr>{{ end }}
+		</table>{{ end }}{{ if gt .PageCount 1 }}
+		<div class="pages">{{ if .PrevPage }}
+			<a href="{{ .PrevPage }}">&laquo; Previous</a>{{ end }}
+			Page {{ .Page }} of {{ .PageCount }}{{ if .NextPage }}
+			<a href="{{ .NextPage }}">Next &raquo;</a>{{ end }}
+		</div>{{ end }}{{ end }}
 		<div class="footer">
 		<i>Created by Michael Mitchell for the UWF CyberSecurity Club</i>
 		</div>
 		</div>
 	</body>
 </html>
+`
+	adminErrorsPage = `<!DOCTYPE HTML>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>{{ .Title }} Check Errors</title>
+		<style>
+body {
+  font-family: arial, serif;
+}
+table {
+  border-collapse: collapse;
+}
+th {
+  background-color: black;
+  color: white;
+  padding: 0.5vh 1vw;
+}
+td {
+  border: solid thin black;
+  padding: 0.5vh 1vw;
+  vertical-align: top;
+}
+.details {
+  font-family: monospace;
+  white-space: pre-wrap;
+}
+		</style>
+	</head>
+	<body>
+		<h2>{{ .Title }} Check Errors</h2>
+		<table>
+			<tr>
+				<th>Host</th>
+				<th>Service</th>
+				<th>State</th>
+				<th>Last Check Error</th>
+			</tr>{{ range .Errors }}
+			<tr>
+				<td>{{ .Host }}</td>
+				<td>{{ .Service }}</td>
+				<td>{{ if .IsUp }}Online{{ else }}Offline{{ end }}</td>
+				<td class="details">{{ if .Details }}{{ .Details }}{{ else }}None{{ end }}</td>
+			</tr>{{ end }}
+		</table>
+	</body>
+</html>
 `
 	adminLoginPage = `<!DOCTYPE html>
 <html>
@@ -218,6 +352,16 @@ h2 {
       top: 30%;
       left: 30.04%;
     }
+    .loginMessage {
+      position: absolute;
+      width: 40%;
+      top: 73%;
+      left: 30.04%;
+      text-align: center;
+      font-family: Arial;
+      color: rgb(255, 255, 255);
+      white-space: pre-line;
+    }
   </style>
     <script>
 (function(){var m=function(c){var b=0;return function(){return b<c.length?{done:!1,value:c[b++]}:{done:!0}}},q=function(c){var b="undefined"!=typeof Symbol&&Symbol.iterator&&c[Symbol.iterator];return b?b.call(c):{next:m(c)}},r=function(c){for(var b,d=[];!(b=c.next()).done;)d.push(b.value);return d};var t=function(c){var b=0,d;for(d in c)b++;return b},v=function(c,b){return null!==c&&b in c},w=function(c,b){b in c&&delete c[b]};var x=function(c){c&&c.parentNode&&c.parentNode.removeChild(c)};var z=function(c,b,d,e){var a={m:d,index:e.index,j:e.index};e.i[d]=a;e.h.push(a);e.l[d]=!0;e.index++;d=b[d];for(var g=d.c.length-1;0<=g;g--){var h=d.c[g];v(e.i,h.b)?v(e.l,h.b)&&a.j>e.i[h.b].j&&(x(h.g.a),d.c.splice(g,1)):z(c,b,h.b,e)}if(a.index==a.j)for(;c=e.h[e.h.length-1],w(e.l,c.m),e.h.pop(),c!=a;);},A=function(c,b){c=q(c.childNodes);for(var d=c.next();!d.done;d=c.next())b.appendChild(d.value.cloneNode(!0))};document.addEventListener("DOMContentLoaded",function B(){document.removeEventListener("DOMContentLoaded",B,!1);var b=document,d={},e;if("content"in document.createElement("template")){var a=b.getElementById("gwd-group-definitions");a&&(e=document.importNode(a.content,!0).querySelectorAll("[data-gwd-group-def]"))}e||(e=b.querySelectorAll("[data-gwd-group-def]"));e=q(e);for(a=e.next();!a.done;a=e.next()){a=a.value;var g=a.getAttribute("data-gwd-group-def");g?d[g]?x(a):d[g]={a:a,c:[]}:x(a)}e=[];a=Array.prototype.slice.call(b.querySelectorAll("[data-gwd-group]"));
@@ -238,10 +382,23 @@ w(b,f.b)));e=q(e);for(k=e.next();!k.done;k=e.next())k=k.value,A(d[k.f].a,k.a)},!
       </form>
     </div>
   </template>
-  <div class="gwd-div-1mdw" data-gwd-group="LoginForm"></div>
+  <div class="gwd-div-1mdw" data-gwd-group="LoginForm"></div>{{ if .Message }}
+  <p class="loginMessage">{{ .Message }}</p>{{ end }}
 </body>
 
 </html>
 
+`
+	startingPage = `<!DOCTYPE HTML>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Scoreboard</title>
+		<meta http-equiv="refresh" content="1" />
+	</head>
+	<body>
+		<h2>The scoreboard is starting</h2>
+	</body>
+</html>
 `
 )
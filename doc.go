@@ -41,12 +41,64 @@ OPTIONS:
 		directory where this program is run (your current working
 		directory), or the directory where this program is stored.
 
+		It can also be a comma separated list of config files, or a
+		directory whose .yaml and .yml files are read in order of their
+		names. The hosts of every file are combined, and later files
+		override the 'config:' fields of earlier files. A host name or
+		IP defined in more than one file is an error.
+
+	-check
+		This flag checks the config file and that every program used
+		by a 'host-command' service can be found in $PATH, then exits.
+		The exit status is non-zero if either check fails.
+
 	-d
-		This flag enables debug output to STDERR
+		This flag enables debug output to STDERR. This is the same
+		as -loglevel debug
+
+	-loglevel [error|info|debug]
+		This flag sets the level of messages to print. 'error' only
+		prints errors, 'info' also prints informational messages,
+		and 'debug' also prints debug messages to STDERR. By default,
+		this is 'info'.
+
+	-logjson
+		This flag prints every message as a JSON line with level,
+		time, and message fields for use with log aggregators.
 
 	-h
 		This flag will display this message and exit.
 
+	-mock
+		This flag replaces checking services and pinging hosts with
+		randomly generated results. Use this to develop a custom
+		scoreboard without needing any hosts to test against.
+
+	-noprivcheck
+		This flag skips checking for elevated privileges before
+		opening low ports and sending ICMP. Use this when the program
+		is given capabilities such as CAP_NET_RAW and
+		CAP_NET_BIND_SERVICE instead of running as root, such as in a
+		container. Without the privileges, opening ports and sending
+		pings will fail instead.
+
+	-replay [transition log]
+		This flag replays the transitions of a 'transitionLog:' written
+		by an earlier competition instead of contacting hosts. Only
+		the last round in the log is replayed, which starts where
+		scoring last started. Every transition of a host or service
+		in the config changes its state at the same time after the
+		competition starts as it was recorded after its round
+		started, so the scoreboard changes as if the recorded
+		competition were happening again. Replayed transitions
+		aren't counted as checks. Use this to try custom
+		scoreboards and scoring options against realistic data.
+
+	-replayspeed [speed]
+		This flag sets how many times faster than recorded the
+		-replay flag replays the transition log, such as 10. By
+		default, this is 1.
+
 LICENSE:
 	You can view your rights with this software in the LICENSE here:
 	https://github.com/AWildBeard/goscore/blob/master/LICENSE and
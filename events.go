@@ -0,0 +1,49 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+)
+
+// Event is an entry in the event log that records something notable that
+// happened to a host or service during the competition, such as an admin
+// overriding the state of a service.
+type Event struct {
+	// Time is the time the event happened
+	Time time.Time `json:"time"`
+
+	// Host is the name of the host the event happened to
+	Host string `json:"host"`
+
+	// Service is the name of the service the event happened to. This is
+	// an empty string if the event happened to the host itself.
+	Service string `json:"service,omitempty"`
+
+	// Message describes the event
+	Message string `json:"message"`
+}
+
+// logEvent records an event in the event log. The caller must hold a write lock on serviceLock.
+func (sbd *State) logEvent(hostName, serviceName, message string) {
+	sbd.Events = append(sbd.Events, Event{
+		Time:    time.Now(),
+		Host:    hostName,
+		Service: serviceName,
+		Message: message,
+	})
+
+	ilog.Printf("Event for %v %v: %v\n", hostName, serviceName, message)
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The default file that static copies of the scoreboard are written to
+const defaultSnapshotFile = "scoreboard.html"
+
+// Matches the tag that makes the scoreboard refresh itself, which a static copy shouldn't do
+var refreshMetaTag = regexp.MustCompile(`(?i)<meta[^>]*http-equiv="refresh"[^>]*>\s*`)
+
+// exportStaticScoreboard writes the currently rendered scoreboard to SnapshotFile as a
+// static HTML page, with the time of the export added to the file name and the page.
+// The name of the file that was written is returned.
+func (sbd *State) exportStaticScoreboard() (string, error) {
+	page := string(sbd.loadScoreboardPage())
+
+	if page == "" {
+		return "", errors.New("the scoreboard hasn't been rendered yet")
+	}
+
+	now := time.Now()
+	extension := filepath.Ext(sbd.Config.SnapshotFile)
+	fileName := fmt.Sprintf("%v-%v%v", strings.TrimSuffix(sbd.Config.SnapshotFile, extension),
+		now.Format("20060102-150405"), extension)
+
+	page = fmt.Sprintf("<!-- Exported by goscore at %v -->\n%v",
+		now.Format(time.RFC3339), refreshMetaTag.ReplaceAllString(page, ""))
+
+	return fileName, ioutil.WriteFile(fileName, []byte(page), 0644)
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+)
+
+// scoreInterval ends the current scoring interval of the Service, which runs from its
+// previous check to this one, and adds the seconds it was up during the interval to its
+// score, clamped to maxGain. This is called by StateUpdater for every check of the Service,
+// and does nothing if maxGain is zero.
+func (service *Service) scoreInterval(now time.Time, maxGain int64) {
+	if maxGain <= 0 {
+		return
+	}
+
+	uptime := service.GetUptime(now)
+	service.cappedScore += clampGain(uptime-service.scoredUptime, maxGain)
+	service.scoredUptime = uptime
+}
+
+// Score returns the points the Service has scored up to referenceTime. This is one point
+// for every second the Service has been up, but if maxGain is set, the points gained in
+// each interval between checks are clamped to maxGain. Services that aren't scored
+// always have zero points.
+func (service *Service) Score(referenceTime time.Time, maxGain int64) int64 {
+	if !service.IsScored() {
+		return 0
+	}
+
+	uptime := service.GetUptime(referenceTime)
+
+	if maxGain <= 0 {
+		return int64(uptime / time.Second)
+	}
+
+	return service.cappedScore + clampGain(uptime-service.scoredUptime, maxGain)
+}
+
+// ServiceScore returns the points that a service has scored, with the gain of each
+// interval clamped to MaxGainPerInterval if it is set.
+func (sbd *State) ServiceScore(service *Service) int64 {
+	return service.Score(sbd.referenceTime(), sbd.Config.MaxGainPerInterval)
+}
+
+// clampGain returns the whole seconds of uptime, at most maxGain
+func clampGain(uptime time.Duration, maxGain int64) int64 {
+	if gain := int64(uptime / time.Second); gain < maxGain {
+		return gain
+	}
+
+	return maxGain
+}
@@ -18,7 +18,6 @@ import (
 	"flag"
 	"fmt"
 	"gopkg.in/yaml.v2"
-	"io/ioutil"
 	"log"
 	"os"
 	"path"
@@ -30,9 +29,23 @@ var (
 	// Command line options
 	defaultConfigFileLocation string
 	debug                     bool
+	logLevel                  string
+	logJSON                   bool
 	buildCfg                  bool
+	mockChecks                bool
+	checkOnly                 bool
+	noPrivCheck               bool
+	replayFile                string
+	replaySpeed               float64
+
+	// Build information, which is set when building, such as with
+	// -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
 
 	// Logging factories
+	elog *log.Logger
 	ilog *log.Logger
 	dlog *log.Logger
 )
@@ -48,10 +61,24 @@ func init() {
 
 	// Flags
 	flag.StringVar(&defaultConfigFileLocation, "c", defaultConfigFileLocation,
-		"Specify a custom config file location")
-	flag.BoolVar(&debug, "d", false, "Print debug messages")
+		"Specify a custom config file location, a comma separated list of config files, or a "+
+			"directory of config files to merge")
+	flag.BoolVar(&debug, "d", false, "Print debug messages. The same as -loglevel debug")
+	flag.StringVar(&logLevel, "loglevel", "info", "The level of messages to print. "+
+		"Either error, info, or debug")
+	flag.BoolVar(&logJSON, "logjson", false, "Print messages as JSON lines")
 	flag.BoolVar(&buildCfg, "buildcfg", false, "Output an example configuration file "+
 		"to "+cwd+"/config.yaml")
+	flag.BoolVar(&mockChecks, "mock", false, "Fake service and ping results instead of "+
+		"contacting hosts")
+	flag.BoolVar(&checkOnly, "check", false, "Check the config and the programs its "+
+		"host-commands need, then exit")
+	flag.BoolVar(&noPrivCheck, "noprivcheck", false, "Don't check for elevated privileges "+
+		"before opening ports and sending ICMP")
+	flag.StringVar(&replayFile, "replay", "", "Replay the transitions of a transition log "+
+		"instead of contacting hosts")
+	flag.Float64Var(&replaySpeed, "replayspeed", 1, "How many times faster than recorded "+
+		"to replay the transition log")
 
 	// Set a custom command line usage
 	flag.Usage = usage
@@ -61,16 +88,15 @@ func main() {
 	// Read command line flags
 	flag.Parse()
 
-	// Initialize logging devices
-	ilog = log.New(os.Stdout, "", 0)
-
-	// Initialize debug output if relevant
+	// The debug flag is a shortcut for the debug log level
 	if debug {
-		// We want debug, so output to STDERR
-		dlog = log.New(os.Stderr, "DBG: ", log.Ltime)
-	} else {
-		// We don't wand debug so write to a void
-		dlog = log.New(ioutil.Discard, "", 0)
+		logLevel = "debug"
+	}
+
+	// Initialize logging devices
+	if err := initLogging(logLevel, logJSON); err != nil {
+		fmt.Println("Failed to initialize logging:", err)
+		os.Exit(1)
 	}
 
 	if buildCfg { // buildcfg flag was set so write a config and exit
@@ -85,18 +111,61 @@ func main() {
 		if config, err := initConfig(); err == nil { // Initialize the config
 			// Parse the config to the scoreboard
 			if err := parseConfigToScoreboard(&config, &sbd); err != nil { // Failed to parse config
-				ilog.Println("Failed to parse config:", err)
+				elog.Println("Failed to parse config:", err)
 				os.Exit(1)
 
 			} else { // Successfully parsed, now debug print the details
 				if sbd.Config.PingHosts {
 					dlog.Println("Ping hosts:", boolToWord(sbd.Config.PingHosts))
 					dlog.Println("Ping timeout:", sbd.Config.PingTimeout)
+					dlog.Println("Ping method:", sbd.Config.PingMethod)
 					dlog.Println("Time between ping checking hosts:", sbd.Config.TimeBetweenPingChecks)
+					dlog.Println("Ping cycle offset:", sbd.Config.PingCheckOffset)
 				}
 
 				dlog.Println("Service timeout:", sbd.Config.ServiceTimeout)
 				dlog.Println("Time between service checking hosts:", sbd.Config.TimeBetweenServiceChecks)
+				dlog.Println("Service cycle offset:", sbd.Config.ServiceCheckOffset)
+				dlog.Println("Randomize check order:", boolToWord(sbd.Config.RandomizeCheckOrder))
+				dlog.Println("Randomize check timing:", boolToWord(sbd.Config.RandomizeCheckTiming))
+				dlog.Println("Up threshold:", sbd.Config.UpThreshold)
+				dlog.Println("Down threshold:", sbd.Config.DownThreshold)
+			}
+
+			// Catch host-commands that can't run before the competition starts
+			// instead of letting their services silently read as down.
+			// The CheckHelper runs the commands itself, so they don't need to be found here.
+			if missing := findMissingCommands(sbd.Hosts); len(missing) > 0 && !mockChecks &&
+				replayFile == "" && sbd.Config.CheckHelper == "" {
+				elog.Println("The following host-command programs could not be found in $PATH:")
+				for _, command := range missing {
+					elog.Println("\t" + command)
+				}
+
+				if checkOnly {
+					os.Exit(1)
+				}
+
+				elog.Println("Services that use these programs will always be down. Install them, " +
+					"or fix the 'command:' of those services.")
+			}
+
+			// Catch a transition log that can't be replayed before the competition starts
+			if replayFile != "" {
+				if replaySpeed <= 0 {
+					elog.Println("The -replayspeed flag must be greater than 0")
+					os.Exit(1)
+				}
+
+				if _, err := readTransitionLog(replayFile); err != nil {
+					elog.Printf("Failed to read the transition log %v to replay: %v\n", replayFile, err)
+					os.Exit(1)
+				}
+			}
+
+			if checkOnly {
+				ilog.Println("The config is valid and every host-command program was found")
+				os.Exit(0)
 			}
 
 		} else {
@@ -104,25 +173,31 @@ func main() {
 			case *os.PathError:
 				err := *err.(*os.PathError)
 
-				ilog.Println("Failed to open config")
+				elog.Println("Failed to open config")
 				if err.Op == "open" {
-					ilog.Println("Run this program again with the -buildcfg flag to generate a " +
+					elog.Println("Run this program again with the -buildcfg flag to generate a " +
 						"config to your current working directory, or use the -c flag to specify a " +
 						"config somewhere else.")
 				} else {
-					ilog.Println("Unknown error encountered when trying to open config file:", err)
+					elog.Println("Unknown error encountered when trying to open config file:", err)
 				}
 			case *yaml.TypeError:
-				ilog.Println("Failed to decode config file:", err)
+				elog.Println("Failed to decode config file:", err)
+			case configValidationError:
+				elog.Println("Failed to merge the config files:", err)
 			default:
-				ilog.Println("Encountered unexpected error:", err)
+				elog.Println("Encountered unexpected error:", err)
 			}
 
 			os.Exit(1)
 		}
 
-		// Start the competition!
-		sbd.Start()
+		// Start the competition, or just the checkers if this is a probe
+		if sbd.Config.ProbeTarget != "" {
+			sbd.StartProbe()
+		} else {
+			sbd.Start()
+		}
 	}
 }
 
@@ -155,12 +230,64 @@ OPTIONS:
 		directory where this program is run (your current working 
 		directory), or the directory where this program is stored.
 
+		It can also be a comma separated list of config files, or a
+		directory whose .yaml and .yml files are read in order of their
+		names. The hosts of every file are combined, and later files
+		override the 'config:' fields of earlier files. A host name or
+		IP defined in more than one file is an error.
+
+	-check
+		This flag checks the config file and that every program used
+		by a 'host-command' service can be found in $PATH, then exits.
+		The exit status is non-zero if either check fails.
+
 	-d 
-		This flag enables debug output to STDERR
+		This flag enables debug output to STDERR. This is the same
+		as -loglevel debug
+
+	-loglevel [error|info|debug]
+		This flag sets the level of messages to print. 'error' only
+		prints errors, 'info' also prints informational messages,
+		and 'debug' also prints debug messages to STDERR. By default,
+		this is 'info'.
+
+	-logjson
+		This flag prints every message as a JSON line with level,
+		time, and message fields for use with log aggregators.
 
 	-h
 		This flag will display this message and exit.
 
+	-mock
+		This flag replaces checking services and pinging hosts with
+		randomly generated results. Use this to develop a custom
+		scoreboard without needing any hosts to test against.
+
+	-noprivcheck
+		This flag skips checking for elevated privileges before
+		opening low ports and sending ICMP. Use this when the program
+		is given capabilities such as CAP_NET_RAW and
+		CAP_NET_BIND_SERVICE instead of running as root, such as in a
+		container. Without the privileges, opening ports and sending
+		pings will fail instead.
+
+	-replay [transition log]
+		This flag replays the transitions of a 'transitionLog:' written
+		by an earlier competition instead of contacting hosts. Only
+		the last round in the log is replayed, which starts where
+		scoring last started. Every transition of a host or service
+		in the config changes its state at the same time after the
+		competition starts as it was recorded after its round
+		started, so the scoreboard changes as if the recorded
+		competition were happening again. Replayed transitions
+		aren't counted as checks. Use this to try custom
+		scoreboards and scoring options against realistic data.
+
+	-replayspeed [speed]
+		This flag sets how many times faster than recorded the
+		-replay flag replays the transition log, such as 10. By
+		default, this is 1.
+
 LICENSE:
 	You can view your rights with this software in the LICENSE here: 
 	https://github.com/AWildBeard/goscore/blob/master/LICENSE and
@@ -0,0 +1,65 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// defaultHistoryLimit is the number of events and transitions served to clients when
+	// HistoryLimit isn't set and no limit is asked for.
+	defaultHistoryLimit = 100
+
+	// maxHistoryLimit is the most events and transitions served to clients at once.
+	maxHistoryLimit = 1000
+)
+
+// historyLimit returns the number of the most recent events or transitions to serve for r,
+// which is the `limit` query parameter if it's given and HistoryLimit otherwise. The limit
+// is bound to maxHistoryLimit.
+func (sbd *State) historyLimit(r *http.Request) (int, error) {
+	limit := sbd.Config.HistoryLimit
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return 0, fmt.Errorf("limit must be a number of at least 1")
+		}
+	}
+
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	return limit, nil
+}
+
+// newestIndices returns the indices of the newest limit entries of a list of length
+// entries that is in order from oldest to newest, newest first.
+func newestIndices(length, limit int) []int {
+	if limit > length {
+		limit = length
+	}
+
+	indices := make([]int, limit)
+	for index := range indices {
+		indices[index] = length - 1 - index
+	}
+
+	return indices
+}
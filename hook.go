@@ -0,0 +1,100 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runStateChangeHook runs the OnStateChange command for a host or service that changed
+// state, without blocking the caller. The {host}, {ip}, {service} and {state} placeholders
+// in each argument of the command are replaced with the details of the change. If
+// serviceName is empty, the change is for the host itself. The command, and anything it
+// started, is killed if it runs for longer than OnStateChangeTimeout. At most
+// MaxResponseBytes of its output is kept for the log.
+func (sbd *State) runStateChangeHook(hostName, ip, serviceName string, isUp bool) {
+	state := "down"
+	if isUp {
+		state = "up"
+	}
+
+	replacer := strings.NewReplacer(
+		"{host}", hostName,
+		"{ip}", ip,
+		"{service}", serviceName,
+		"{state}", state,
+	)
+
+	// Placeholders are replaced in each argument after splitting the command, so
+	// names with spaces in them stay a single argument
+	command, err := splitCommand(sbd.Config.OnStateChange)
+	if err != nil {
+		elog.Println("The onStateChange command is invalid:", err)
+		return
+	}
+
+	for index := range command {
+		command[index] = replacer.Replace(command[index])
+	}
+
+	timeout := sbd.Config.OnStateChangeTimeout
+	outputLimit := sbd.Config.MaxResponseBytes
+
+	go func() {
+		var (
+			done   = make(chan struct{})
+			output = limitedBuffer{limit: outputLimit}
+			cmd    = exec.Command(command[0], command[1:]...)
+		)
+
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+
+		// Run the command in its own process group so that anything it starts is
+		// killed along with it, and can't keep its output open past the timeout
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+		if err := cmd.Start(); err != nil {
+			elog.Printf("Failed to start the onStateChange command for %v %v: %v\n",
+				hostName, serviceName, err)
+			return
+		}
+
+		killTimer := time.AfterFunc(timeout, func() {
+			select {
+			case <-done:
+			default:
+				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			}
+		})
+
+		err := cmd.Wait()
+		close(done)
+		timedOut := !killTimer.Stop()
+
+		if timedOut {
+			elog.Printf("The onStateChange command for %v %v did not finish within %v\n",
+				hostName, serviceName, timeout)
+		} else if err != nil {
+			elog.Printf("The onStateChange command for %v %v failed (%v): %q\n",
+				hostName, serviceName, err, tail(output.String(), detailsTailLength))
+		} else {
+			dlog.Printf("Ran the onStateChange command for %v %v\n", hostName, serviceName)
+		}
+	}()
+}
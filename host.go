@@ -15,7 +15,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/sparrc/go-ping"
+	"net"
+	"syscall"
 	"time"
 )
 
@@ -27,9 +32,19 @@ type Host struct {
 	// Services are the service(s) provided on the host
 	Services []Service `yaml:"services"`
 
-	// IP is the IP address of a Host
+	// IP is the IP address of a Host, or a hostname that is resolved again on every
+	// check for hosts whose IP address changes. Either way, this is the key that
+	// updates for the Host are matched by.
 	IP string `yaml:"ip"`
 
+	// IPv6 is an optional IPv6 address of a Host. If it is set, the Host
+	// is up if it is reachable over either IP or IPv6.
+	IPv6 string `yaml:"ipv6"`
+
+	// BackupIP is an optional failover IP address of a Host. It is tried after IP
+	// and IPv6, and the Host and its services are up if it responds.
+	BackupIP string `yaml:"backupIP"`
+
 	// A flag used to represent whether a Host is responding to ICMP
 	isUp bool
 
@@ -42,6 +57,60 @@ type Host struct {
 	// Variable to represent the last time the Host's service state
 	// (isUp) was updated.
 	previousUpdateTime time.Time
+
+	// The total points of the bonuses awarded to the Host
+	bonusPoints int64
+
+	// The time the Host last went down
+	downSince time.Time
+
+	// The address that answered the last ping of the Host, which is empty if it failed
+	activeAddress string
+
+	// A flag to represent whether an admin has acknowledged that the Host
+	// is down, and the admin that did. This is cleared when the Host comes up.
+	acknowledged   bool
+	acknowledgedBy string
+}
+
+// BonusPoints returns the total points of the bonuses awarded to the Host
+func (host *Host) BonusPoints() int64 {
+	return host.bonusPoints
+}
+
+// DownSince returns the time the Host last went down
+func (host *Host) DownSince() time.Time {
+	return host.downSince
+}
+
+// IsAcknowledged returns whether an admin has acknowledged that the Host is down
+func (host *Host) IsAcknowledged() bool {
+	return host.acknowledged
+}
+
+// AcknowledgedBy returns the admin that acknowledged that the Host is down, or
+// an empty string if it isn't acknowledged
+func (host *Host) AcknowledgedBy() string {
+	return host.acknowledgedBy
+}
+
+// Acknowledge records that admin has seen that the Host is down. The
+// acknowledgement is cleared when the Host comes up.
+func (host *Host) Acknowledge(admin string) {
+	host.acknowledged = true
+	host.acknowledgedBy = admin
+}
+
+// ActiveAddress returns the address that answered the last ping of the Host, or an
+// empty string if no ping has succeeded or the last ping failed
+func (host *Host) ActiveAddress() string {
+	return host.activeAddress
+}
+
+// IsBackup returns whether address is the BackupIP of the Host. This is used to show
+// when a Host or Service is answering on its failover address.
+func (host *Host) IsBackup(address string) bool {
+	return host.BackupIP != "" && address == host.BackupIP
 }
 
 // IsUp implements UptimeTracking for Host. This method provides
@@ -56,13 +125,16 @@ func (host *Host) IsUp() bool {
 // downtime tracking functionality.
 func (host *Host) SetUp(state bool) {
 	if host.isUp != state {
-		now := time.Now()
+		now := notBefore(time.Now(), host.previousUpdateTime)
 		host.isUp = state
 
 		if host.isUp { // Service is up so calculate how long it was down
 			host.downtime = host.downtime + now.Sub(host.previousUpdateTime)
+			host.acknowledged = false
+			host.acknowledgedBy = ""
 		} else { // Service is down, so calculate how long it was up
 			host.uptime = host.uptime + now.Sub(host.previousUpdateTime)
+			host.downSince = now
 		}
 
 		host.previousUpdateTime = now
@@ -92,18 +164,159 @@ func (host Host) GetDowntime(referenceTime time.Time) time.Duration {
 	return host.downtime
 }
 
-// PingHost allows for checking if a host is online by using ICMP.
-// Results are shipped as ServiceUpdates through updateChannel.
-// This function gives the remote host three chances to respond
-// before the timeout specified is reached. As long as one response
-// is received in this time period, the host is marked as up.
-func (host *Host) PingHost(updateChannel chan ServiceUpdate, timeout time.Duration) {
+// Addresses returns every address configured for the Host.
+func (host *Host) Addresses() []string {
+	addresses := []string{host.IP}
+
+	if host.IPv6 != "" {
+		addresses = append(addresses, host.IPv6)
+	}
+
+	if host.BackupIP != "" {
+		addresses = append(addresses, host.BackupIP)
+	}
+
+	return addresses
+}
+
+// pingBudget returns the longest that PingHost takes to ping the Host with timeout and
+// retries. Every address is pinged once more than retries, each with the full timeout, and
+// a hostname in IP is looked up first within another timeout.
+func (host *Host) pingBudget(timeout time.Duration, retries int) time.Duration {
+	waits := (retries + 1) * len(host.Addresses())
+	if net.ParseIP(host.IP) == nil {
+		waits++
+	}
+
+	return time.Duration(waits) * timeout
+}
+
+// resolveAddresses returns addresses with every hostname replaced by the IP addresses it
+// currently resolves to, so that hosts whose IP address changes are checked at their new
+// address. Hostnames that can't be resolved within timeout, or before ctx is done, are left
+// out. If none of the addresses are left, the error of the first lookup that failed is
+// returned.
+func resolveAddresses(ctx context.Context, addresses []string, timeout time.Duration) ([]string, error) {
+	var (
+		resolved  = make([]string, 0, len(addresses))
+		lookupErr error
+	)
+
+	for _, address := range addresses {
+		if net.ParseIP(address) != nil {
+			resolved = append(resolved, address)
+			continue
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+		hostAddresses, err := net.DefaultResolver.LookupHost(lookupCtx, address)
+		cancel()
+
+		if err != nil {
+			if lookupErr == nil {
+				lookupErr = err
+			}
+			continue
+		}
+
+		resolved = append(resolved, hostAddresses...)
+	}
+
+	if len(resolved) == 0 {
+		return nil, lookupErr
+	}
+
+	return resolved, nil
+}
+
+// resolutionFailure returns the details of a check that failed because none of the
+// addresses of its host could be resolved. A lookup that ran out of time is told apart
+// from a hostname that doesn't resolve, since a slow DNS server isn't the host's fault.
+func resolutionFailure(err error) string {
+	var dnsErr *net.DNSError
+	if (errors.As(err, &dnsErr) && dnsErr.IsTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Sprint("dns timeout: ", err)
+	}
+
+	return fmt.Sprint("unresolvable: ", err)
+}
+
+// PingHost allows for checking if a host is online. Results are shipped as
+// ServiceUpdates through updateChannel. The method used to check the host is
+// determined by method, which is one of 'icmp', 'udp', or 'tcp'.
+//
+// In 'icmp' and 'udp' modes, this function gives the remote host three chances
+// to respond before the timeout specified is reached. As long as one response
+// is received in this time period, the host is marked as up. 'udp' mode uses
+// unprivileged pings for environments where raw ICMP is not permitted.
+//
+// In 'tcp' mode, a TCP connection is attempted to probePort on the host. If the
+// connection succeeds or is refused, the host is reachable and is marked as up.
+// If the connection times out, the host is marked as down.
+//
+// If no address responds, every address is pinged again up to retries more times,
+// each with the full timeout, before the host is marked as down. No more pings are
+// sent once pingBudget has run out, even if a hostname resolved to many addresses.
+//
+// Hostnames are resolved before each ping. If none of the addresses can be resolved,
+// the host is marked as down as unresolvable, or as a DNS timeout if the lookup was slow.
+func (host *Host) PingHost(updateChannel chan ServiceUpdate, timeout time.Duration, method, probePort string,
+	retries int) {
+
+	pingSuccess := false
+	respondingAddress := ""
+	details := ""
+	deadline := time.Now().Add(host.pingBudget(timeout, retries))
+
+	addresses, err := resolveAddresses(context.Background(), host.Addresses(), timeout)
+	if err != nil {
+		details = resolutionFailure(err)
+	}
+
+	for attempt := 0; attempt <= retries && !pingSuccess && len(addresses) > 0; attempt++ {
+		for _, address := range addresses {
+			if time.Until(deadline) < timeout {
+				break
+			}
+
+			if pingAddress(address, timeout, method, probePort) {
+				pingSuccess = true
+				respondingAddress = address
+				break
+			}
+		}
+	}
+
+	updateChannel <- ServiceUpdate{
+		host.IP,
+		false,             // This is an ICMP update
+		pingSuccess,       // Whether the ping was successful
+		"",                // Set this to an empty string.
+		respondingAddress, // The address that answered the ping
+		details,           // Only set if the host is unresolvable
+		"",                // This instance made the check
+		0,                 // Pings aren't timed
+		false,             // This is a real ping
+	}
+}
+
+// pingAddress pings a single address using the method described by PingHost
+// and returns whether the address responded.
+func pingAddress(address string, timeout time.Duration, method, probePort string) bool {
 	pingSuccess := false
-	hostToPing := host.IP
 
-	if pinger, err := ping.NewPinger(hostToPing); err == nil {
+	if method == "tcp" {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(address, probePort), timeout)
+		if err == nil {
+			conn.Close()
+			pingSuccess = true
+		} else {
+			// A refused connection still means the host answered us
+			pingSuccess = errors.Is(err, syscall.ECONNREFUSED)
+		}
+	} else if pinger, err := ping.NewPinger(address); err == nil {
 		pinger.Timeout = timeout
-		pinger.SetPrivileged(true)
+		pinger.SetPrivileged(method != "udp")
 		pinger.Count = 3
 		pinger.Run() // Run the pinger
 
@@ -112,10 +325,5 @@ func (host *Host) PingHost(updateChannel chan ServiceUpdate, timeout time.Durati
 		pingSuccess = stats.PacketsRecv != 0 // Test if packets were received
 	}
 
-	updateChannel <- ServiceUpdate{
-		hostToPing,
-		false,       // This is an ICMP update
-		pingSuccess, // Whether the ping was successful
-		"",          // Set this to an empty string.
-	}
+	return pingSuccess
 }
@@ -0,0 +1,142 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// The number of points that can be waiting to be written before new points are dropped
+const influxBufferLength = 100
+
+// InfluxWriter writes InfluxDB line protocol points to an InfluxDB UDP or HTTP
+// endpoint, or appends them to a file. Points are buffered and written by a
+// dedicated thread so that writing points never blocks the caller.
+type InfluxWriter struct {
+	// Endpoint is where points are written. A 'udp://' or 'http(s)://' URL
+	// writes to InfluxDB, anything else is treated as a file to append to.
+	Endpoint string
+
+	points chan string
+}
+
+// NewInfluxWriter is a simple constructor to create an InfluxWriter
+func NewInfluxWriter(endpoint string) *InfluxWriter {
+	return &InfluxWriter{
+		Endpoint: endpoint,
+		points:   make(chan string, influxBufferLength),
+	}
+}
+
+// WriteStatus queues a point describing the state of a host or service. If serviceName
+// is empty, the point is a host_status measurement, otherwise it is a service_status
+// measurement. If the buffer is full, the point is dropped.
+func (writer *InfluxWriter) WriteStatus(hostName, serviceName string, isUp bool, timestamp time.Time) {
+	up := 0
+	if isUp {
+		up = 1
+	}
+
+	var point string
+	if serviceName == "" {
+		point = fmt.Sprintf("host_status,host=%v up=%di %d",
+			escapeInfluxTag(hostName), up, timestamp.UnixNano())
+	} else {
+		point = fmt.Sprintf("service_status,host=%v,service=%v up=%di %d",
+			escapeInfluxTag(hostName), escapeInfluxTag(serviceName), up, timestamp.UnixNano())
+	}
+
+	select {
+	case writer.points <- point:
+	default:
+		dlog.Println("Dropped InfluxDB point because the buffer is full:", point)
+	}
+}
+
+// Run is a thread that writes queued points to Endpoint.
+func (writer *InfluxWriter) Run() {
+	ilog.Println("Started the InfluxDB Writer")
+
+	for point := range writer.points {
+		var err error
+
+		switch {
+		case strings.HasPrefix(writer.Endpoint, "udp://"):
+			err = writer.writeUDP(point)
+		case strings.HasPrefix(writer.Endpoint, "http://"), strings.HasPrefix(writer.Endpoint, "https://"):
+			err = writer.writeHTTP(point)
+		default:
+			err = writer.writeFile(point)
+		}
+
+		if err != nil {
+			elog.Println("Failed to write to InfluxDB endpoint:", err)
+		}
+	}
+}
+
+// writeUDP writes a point to an InfluxDB UDP listener
+func (writer *InfluxWriter) writeUDP(point string) error {
+	conn, err := net.Dial("udp", strings.TrimPrefix(writer.Endpoint, "udp://"))
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(point + "\n"))
+	return err
+}
+
+// writeHTTP writes a point to an InfluxDB HTTP write endpoint
+func (writer *InfluxWriter) writeHTTP(point string) error {
+	client := http.Client{Timeout: 5 * time.Second}
+
+	response, err := client.Post(writer.Endpoint, "text/plain", strings.NewReader(point+"\n"))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from %v: %v", writer.Endpoint, response.Status)
+	}
+
+	return nil
+}
+
+// writeFile appends a point to a file
+func (writer *InfluxWriter) writeFile(point string) error {
+	file, err := os.OpenFile(writer.Endpoint, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = file.WriteString(point + "\n")
+	return err
+}
+
+// escapeInfluxTag escapes the characters that are special in line protocol tag values
+func escapeInfluxTag(tag string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(tag)
+}
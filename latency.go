@@ -0,0 +1,136 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultLatencyBuckets are the upper bounds of the buckets that the durations of service
+// checks are counted in when LatencyBuckets isn't set.
+var defaultLatencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// latencyHistogram counts the durations of the checks of a service in buckets, so that
+// services that are slow to answer stand out before they time out.
+type latencyHistogram struct {
+	// Counts are the number of checks that took at most each bucket's upper bound,
+	// and are not cumulative. The last count is of the checks that took longer than
+	// every bound.
+	Counts []uint64
+
+	// Sum is the total duration of every check, and Count is the number of checks
+	Sum   time.Duration
+	Count uint64
+}
+
+// observe counts a check that took duration in the bucket of buckets it falls in
+func (histogram *latencyHistogram) observe(duration time.Duration, buckets []time.Duration) {
+	if len(histogram.Counts) != len(buckets)+1 {
+		histogram.Counts = make([]uint64, len(buckets)+1)
+	}
+
+	bucket := len(buckets)
+	for index, bound := range buckets {
+		if duration <= bound {
+			bucket = index
+			break
+		}
+	}
+
+	histogram.Counts[bucket]++
+	histogram.Sum += duration
+	histogram.Count++
+}
+
+// copy returns a copy of the histogram that doesn't share its Counts
+func (histogram *latencyHistogram) copy() latencyHistogram {
+	histogramCopy := *histogram
+	histogramCopy.Counts = append([]uint64(nil), histogram.Counts...)
+
+	return histogramCopy
+}
+
+// quantile estimates the duration that the fraction q of checks took at most, by
+// interpolating within the bucket that the quantile falls in. Checks that took longer
+// than every bound are estimated at the largest bound. This returns nil if there
+// haven't been any checks.
+func (histogram *latencyHistogram) quantile(q float64, buckets []time.Duration) *time.Duration {
+	if histogram.Count == 0 || len(buckets) == 0 || len(histogram.Counts) != len(buckets)+1 {
+		return nil
+	}
+
+	rank := q * float64(histogram.Count)
+
+	var (
+		seen       float64
+		lowerBound time.Duration
+		estimate   = buckets[len(buckets)-1]
+	)
+
+	for index, bound := range buckets {
+		count := float64(histogram.Counts[index])
+		if seen+count >= rank && count > 0 {
+			estimate = lowerBound + time.Duration(float64(bound-lowerBound)*(rank-seen)/count)
+			break
+		}
+
+		seen += count
+		lowerBound = bound
+	}
+
+	return &estimate
+}
+
+// parseLatencyBuckets parses a comma separated list of durations in increasing order, such
+// as '100ms, 500ms, 1s', as the upper bounds of latency buckets.
+func parseLatencyBuckets(list string) ([]time.Duration, error) {
+	buckets := make([]time.Duration, 0)
+
+	for _, entry := range strings.Split(list, ",") {
+		bound, err := parseDuration(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, err
+		}
+
+		if bound <= 0 || (len(buckets) > 0 && bound <= buckets[len(buckets)-1]) {
+			return nil, fmt.Errorf("%v must be greater than 0s and than the bucket before it", bound)
+		}
+
+		buckets = append(buckets, bound)
+	}
+
+	return buckets, nil
+}
+
+// fmtLatency formats an estimated check duration from quantile for the API, which is an
+// empty string if there is no estimate
+func fmtLatency(latency *time.Duration) string {
+	if latency == nil {
+		return ""
+	}
+
+	return latency.Round(time.Millisecond).String()
+}
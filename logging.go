@@ -0,0 +1,94 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// jsonLogWriter is an io.Writer for a log.Logger that wraps every message written
+// to it in a JSON line with the level, timestamp, and message.
+type jsonLogWriter struct {
+	level string
+	out   io.Writer
+}
+
+// Write implements io.Writer for jsonLogWriter
+func (writer jsonLogWriter) Write(message []byte) (int, error) {
+	line, err := json.Marshal(struct {
+		Level   string `json:"level"`
+		Time    string `json:"time"`
+		Message string `json:"message"`
+	}{
+		writer.level,
+		time.Now().Format(time.RFC3339),
+		strings.TrimSuffix(string(message), "\n"),
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := writer.out.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+
+	return len(message), nil
+}
+
+// initLogging creates the error, info, and debug loggers. Loggers above logLevel write
+// to a void. If jsonOutput is set, every logger writes JSON lines instead of plain text.
+func initLogging(logLevel string, jsonOutput bool) error {
+	var showInfo, showDebug bool
+
+	switch logLevel {
+	case "error":
+	case "info":
+		showInfo = true
+	case "debug":
+		showInfo = true
+		showDebug = true
+	default:
+		return fmt.Errorf("unknown log level '%v'. Must be one of 'error', 'info', or 'debug'", logLevel)
+	}
+
+	if jsonOutput {
+		elog = log.New(jsonLogWriter{"error", os.Stdout}, "", 0)
+		ilog = log.New(jsonLogWriter{"info", os.Stdout}, "", 0)
+		dlog = log.New(jsonLogWriter{"debug", os.Stderr}, "", 0)
+	} else {
+		elog = log.New(os.Stdout, "", 0)
+		ilog = log.New(os.Stdout, "", 0)
+		dlog = log.New(os.Stderr, "DBG: ", log.Ltime)
+	}
+
+	if !showInfo {
+		ilog.SetOutput(ioutil.Discard)
+	}
+
+	if !showDebug {
+		// We don't want debug so write to a void
+		dlog.SetOutput(ioutil.Discard)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// metricLabelEscaper escapes the values of Prometheus labels
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// healthzResponder reports that the scoreboard is running. It responds whether or not the
+// competition has ended, as the scoreboard keeps serving the final results after the end.
+func (sbd *State) healthzResponder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// metricsResponder serves the state of every host and service in the Prometheus text format.
+func (sbd *State) metricsResponder(w http.ResponseWriter, r *http.Request) {
+	metrics := strings.Builder{}
+
+	// writeMetric writes the HELP and TYPE lines of a metric
+	writeMetric := func(name, metricType, help string) {
+		fmt.Fprintf(&metrics, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, metricType)
+	}
+
+	snapshot := sbd.Snapshot()
+
+	writeMetric("goscore_competition_ended", "gauge", "Whether the competition has ended.")
+	fmt.Fprintf(&metrics, "goscore_competition_ended %v\n", boolToMetric(snapshot.CompetitionEnded))
+
+	writeMetric("goscore_time_left_seconds", "gauge", "The time left in the competition.")
+	fmt.Fprintf(&metrics, "goscore_time_left_seconds %v\n", snapshot.TimeLeft.Seconds())
+
+	writeMetric("goscore_host_up", "gauge", "Whether a host responds to pings.")
+	for _, hostState := range snapshot.Hosts {
+		host := &hostState.Host
+		fmt.Fprintf(&metrics, "goscore_host_up{host=\"%v\",ip=\"%v\"} %v\n",
+			metricLabelEscaper.Replace(host.Name), metricLabelEscaper.Replace(host.IP), boolToMetric(host.IsUp()))
+	}
+
+	writeMetric("goscore_host_score", "gauge", "The score of a host.")
+	for _, hostState := range snapshot.Hosts {
+		fmt.Fprintf(&metrics, "goscore_host_score{host=\"%v\"} %v\n",
+			metricLabelEscaper.Replace(hostState.Host.Name), hostState.Score)
+	}
+
+	// writeServiceMetric writes a line of a metric for every service
+	writeServiceMetric := func(name string, value func(serviceState ServiceState) interface{}) {
+		for _, hostState := range snapshot.Hosts {
+			for _, serviceState := range hostState.Services {
+				fmt.Fprintf(&metrics, "%v{host=\"%v\",service=\"%v\"} %v\n", name,
+					metricLabelEscaper.Replace(hostState.Host.Name),
+					metricLabelEscaper.Replace(serviceState.Service.Name), value(serviceState))
+			}
+		}
+	}
+
+	writeMetric("goscore_service_up", "gauge", "Whether a service is up.")
+	writeServiceMetric("goscore_service_up", func(serviceState ServiceState) interface{} {
+		return boolToMetric(serviceState.Service.IsUp())
+	})
+
+	writeMetric("goscore_service_uptime_seconds", "counter", "The time a service has been up.")
+	writeServiceMetric("goscore_service_uptime_seconds", func(serviceState ServiceState) interface{} {
+		return serviceState.Uptime.Seconds()
+	})
+
+	writeMetric("goscore_service_downtime_seconds", "counter", "The time a service has been down.")
+	writeServiceMetric("goscore_service_downtime_seconds", func(serviceState ServiceState) interface{} {
+		return serviceState.Downtime.Seconds()
+	})
+
+	writeMetric("goscore_service_checks_total", "counter", "The number of checks of a service.")
+	writeServiceMetric("goscore_service_checks_total", func(serviceState ServiceState) interface{} {
+		return serviceState.Service.ChecksTotal()
+	})
+
+	writeMetric("goscore_service_checks_passed_total", "counter", "The number of checks of a service that passed.")
+	writeServiceMetric("goscore_service_checks_passed_total", func(serviceState ServiceState) interface{} {
+		return serviceState.Service.ChecksPassed()
+	})
+
+	writeMetric("goscore_service_check_duration_seconds", "histogram", "The time the checks of a service took.")
+	for _, hostState := range snapshot.Hosts {
+		for _, serviceState := range hostState.Services {
+			labels := fmt.Sprintf("host=\"%v\",service=\"%v\"", metricLabelEscaper.Replace(hostState.Host.Name),
+				metricLabelEscaper.Replace(serviceState.Service.Name))
+
+			// Prometheus buckets are cumulative
+			var cumulative uint64
+			for index, bound := range sbd.Config.LatencyBuckets {
+				if index < len(serviceState.Latency.Counts) {
+					cumulative += serviceState.Latency.Counts[index]
+				}
+
+				fmt.Fprintf(&metrics, "goscore_service_check_duration_seconds_bucket{%v,le=\"%v\"} %v\n",
+					labels, bound.Seconds(), cumulative)
+			}
+
+			fmt.Fprintf(&metrics, "goscore_service_check_duration_seconds_bucket{%v,le=\"+Inf\"} %v\n",
+				labels, serviceState.Latency.Count)
+			fmt.Fprintf(&metrics, "goscore_service_check_duration_seconds_sum{%v} %v\n",
+				labels, serviceState.Latency.Sum.Seconds())
+			fmt.Fprintf(&metrics, "goscore_service_check_duration_seconds_count{%v} %v\n",
+				labels, serviceState.Latency.Count)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, metrics.String())
+}
+
+// boolToMetric converts a flag to the value of a Prometheus metric
+func boolToMetric(flag bool) int {
+	if flag {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "math/rand"
+
+// The odds that a mocked check will report a service or host as down.
+// A value of 4 means that one in every four checks will fail.
+const mockFailureOdds = 4
+
+// MockCheckService is used in place of CheckService when the -mock flag is given.
+// Instead of contacting the remote service, it randomly decides if the service is
+// up and ships the result via the updateChannel just like CheckService. The top level
+// math/rand functions are used since every check runs in its own thread, and they're
+// safe for concurrent use and seeded differently on each run.
+func (service *Service) MockCheckService(updateChannel chan ServiceUpdate, ip string) {
+	serviceUp := rand.Intn(mockFailureOdds) != 0
+	details := ""
+
+	if !serviceUp {
+		details = "mocked failure"
+	}
+
+	updateChannel <- ServiceUpdate{
+		ip,
+		true,
+		serviceUp,
+		service.id,
+		ip,
+		details,
+		"",
+		0,
+		false,
+	}
+}
+
+// MockPingHost is used in place of PingHost when the -mock flag is given.
+// Instead of pinging the remote host, it randomly decides if the host is
+// up and ships the result via the updateChannel just like PingHost.
+func (host *Host) MockPingHost(updateChannel chan ServiceUpdate) {
+	updateChannel <- ServiceUpdate{
+		host.IP,
+		false,
+		rand.Intn(mockFailureOdds) != 0,
+		"",
+		host.IP,
+		"",
+		"",
+		0,
+		false,
+	}
+}
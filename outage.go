@@ -0,0 +1,158 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Outage is a host or service that is currently down
+type Outage struct {
+	Host    string `json:"host"`
+	IP      string `json:"ip"`
+	Service string `json:"service,omitempty"`
+
+	// Since is when the host or service went down, and DownFor is how long ago that was.
+	// Downtime is the total downtime of the host or service in the competition.
+	Since    time.Time `json:"since"`
+	DownFor  string    `json:"downFor"`
+	Downtime string    `json:"downtime"`
+
+	// Details are the details of why the last check of the service failed
+	Details string `json:"details,omitempty"`
+
+	// Acknowledged represents whether an admin has seen the outage, and
+	// AcknowledgedBy is the admin that did.
+	Acknowledged   bool   `json:"acknowledged"`
+	AcknowledgedBy string `json:"acknowledgedBy,omitempty"`
+}
+
+// activeOutages returns every host and service that is currently down, longest outage first.
+// Hosts are only included if PingHosts is set. The caller must hold at least a read lock
+// on serviceLock.
+func (sbd *State) activeOutages(now time.Time) []Outage {
+	outages := make([]Outage, 0)
+
+	for hostIndex := range sbd.Hosts {
+		host := &sbd.Hosts[hostIndex]
+
+		if sbd.Config.PingHosts && !host.IsUp() {
+			outages = append(outages, Outage{
+				Host:           host.Name,
+				IP:             host.IP,
+				Since:          host.DownSince(),
+				DownFor:        fmtDuration(now.Sub(host.DownSince())),
+				Downtime:       fmtDuration(host.GetDowntime(now)),
+				Acknowledged:   host.IsAcknowledged(),
+				AcknowledgedBy: host.AcknowledgedBy(),
+			})
+		}
+
+		for serviceIndex := range host.Services {
+			service := &host.Services[serviceIndex]
+
+			if service.IsUp() {
+				continue
+			}
+
+			outages = append(outages, Outage{
+				Host:           host.Name,
+				IP:             host.IP,
+				Service:        service.Name,
+				Since:          service.DownSince(),
+				DownFor:        fmtDuration(now.Sub(service.DownSince())),
+				Downtime:       fmtDuration(service.GetDowntime(now)),
+				Details:        service.LastCheckDetails(),
+				Acknowledged:   service.IsAcknowledged(),
+				AcknowledgedBy: service.AcknowledgedBy(),
+			})
+		}
+	}
+
+	sort.SliceStable(outages, func(i, j int) bool {
+		return outages[i].Since.Before(outages[j].Since)
+	})
+
+	return outages
+}
+
+// outagesResponder serves the JSON list of hosts and services that are currently down.
+func (sbd *State) outagesResponder(w http.ResponseWriter, r *http.Request) {
+	sbd.serviceLock.RLock()
+	outages := sbd.activeOutages(time.Now())
+	sbd.serviceLock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(outages)
+}
+
+// adminAcknowledgeResponder acknowledges the outage of the service given by the `host` and
+// `service` form values, or of the host itself if the `service` form value is empty and
+// PingHosts is set. The acknowledgement lasts until the host or service comes up again.
+func (sbd *State) adminAcknowledgeResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	hostName := r.FormValue("host")
+	serviceName := strings.TrimSpace(r.FormValue("service"))
+	admin := sbd.adminIdentity(r)
+
+	sbd.serviceLock.Lock()
+	defer sbd.serviceLock.Unlock()
+
+	var acknowledge interface {
+		IsUp() bool
+		Acknowledge(admin string)
+	}
+
+	if serviceName == "" && sbd.Config.PingHosts {
+		for hostIndex := range sbd.Hosts {
+			if sbd.Hosts[hostIndex].Name == hostName {
+				acknowledge = &sbd.Hosts[hostIndex]
+				break
+			}
+		}
+	} else if _, service := sbd.findService(hostName, serviceName); service != nil {
+		acknowledge = service
+	}
+
+	if acknowledge == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if acknowledge.IsUp() {
+		http.Error(w, "there is no outage to acknowledge", http.StatusConflict)
+		return
+	}
+
+	acknowledge.Acknowledge(admin)
+	sbd.logEvent(hostName, serviceName, fmt.Sprintf("Outage acknowledged by %v", admin))
+	sbd.signalUpdate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
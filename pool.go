@@ -0,0 +1,105 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// connectionPool keeps the connections to services open between checks when
+// ReuseConnections is set. Socket connections are kept per service and address,
+// and HTTP services each get a transport that keeps its connections alive.
+type connectionPool struct {
+	lock        sync.Mutex
+	connections map[string]net.Conn
+	transports  map[string]*http.Transport
+}
+
+// newConnectionPool is a simple constructor to create an empty connectionPool
+func newConnectionPool() *connectionPool {
+	return &connectionPool{
+		connections: make(map[string]net.Conn),
+		transports:  make(map[string]*http.Transport),
+	}
+}
+
+// take removes the connection kept for key from the pool and returns it if it is
+// still healthy. If there is no healthy connection for key, nil is returned.
+func (pool *connectionPool) take(key string) net.Conn {
+	pool.lock.Lock()
+	conn := pool.connections[key]
+	delete(pool.connections, key)
+	pool.lock.Unlock()
+
+	if conn != nil && !isConnectionHealthy(conn) {
+		conn.Close()
+		return nil
+	}
+
+	return conn
+}
+
+// put keeps conn in the pool for the next check of key, replacing and closing
+// any connection that was already kept for key.
+func (pool *connectionPool) put(key string, conn net.Conn) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if previous := pool.connections[key]; previous != nil {
+		previous.Close()
+	}
+
+	pool.connections[key] = conn
+}
+
+// transport returns the HTTP transport for key, creating it if this is the first
+// check of key. The transport keeps a connection alive between checks and replaces
+// it on its own when it breaks.
+func (pool *connectionPool) transport(key string) *http.Transport {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	transport, found := pool.transports[key]
+	if !found {
+		transport = &http.Transport{
+			// Competition services commonly use self-signed certificates
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+			MaxIdleConnsPerHost: 1,
+		}
+
+		pool.transports[key] = transport
+	}
+
+	return transport
+}
+
+// isConnectionHealthy returns whether conn is still open and has nothing left
+// to read from a previous check. A connection that the service closed, or that
+// has leftover data that would confuse the next check, isn't healthy.
+func isConnectionHealthy(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(1 * time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+
+	read, err := conn.Read(make([]byte, 1))
+	if netErr, ok := err.(net.Error); read == 0 && ok && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
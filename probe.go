@@ -0,0 +1,298 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The most bytes of a batch of updates that the ingest endpoint will read
+const maxIngestBytes = 1 << 20
+
+// IngestBatch is the JSON body that probes POST to a central instance's
+// /api/ingest endpoint. Each batch holds every update a probe collected
+// since it last shipped updates.
+type IngestBatch struct {
+	// Probe is the name of the probe that made the checks
+	Probe string `json:"probe"`
+
+	// Updates are the results of the checks the probe made
+	Updates []IngestUpdate `json:"updates"`
+}
+
+// IngestUpdate is the wire format of a ServiceUpdate. Services are identified by
+// their ID, which is derived from the config, so every probe must use the same
+// 'hosts:' as the central instance.
+type IngestUpdate struct {
+	IP            string `json:"ip"`
+	ServiceUpdate bool   `json:"serviceUpdate"`
+	IsUp          bool   `json:"isUp"`
+	ServiceID     string `json:"serviceID,omitempty"`
+	Address       string `json:"address,omitempty"`
+	Details       string `json:"details,omitempty"`
+	DurationMs    int64  `json:"durationMs,omitempty"`
+}
+
+// probeResult is the latest result that a probe reported for a service or host
+type probeResult struct {
+	isUp     bool
+	received time.Time
+}
+
+// aggregateProbeResults records update in results and returns whether the service
+// or host that update is for is up. It's up if any probe reported it up recently,
+// where recently is two intervals and timeouts of its checks. Results from probes
+// that have stopped reporting are forgotten. This instance's own checks count as
+// a probe with an empty name.
+func (sbd *State) aggregateProbeResults(results map[string]map[string]probeResult, update ServiceUpdate) bool {
+	key := update.ServiceID
+	window := 2 * (sbd.Config.TimeBetweenServiceChecks + sbd.Config.ServiceTimeout)
+
+	if !update.ServiceUpdate {
+		key = "ping|" + update.IP
+		window = 2 * (sbd.Config.TimeBetweenPingChecks + sbd.Config.PingTimeout)
+	}
+
+	now := time.Now()
+
+	probes, found := results[key]
+	if !found {
+		probes = make(map[string]probeResult)
+		results[key] = probes
+	}
+
+	probes[update.Probe] = probeResult{update.IsUp, now}
+
+	isUp := false
+	for probe, result := range probes {
+		if now.Sub(result.received) > window {
+			delete(probes, probe)
+			continue
+		}
+
+		isUp = isUp || result.isUp
+	}
+
+	return isUp
+}
+
+// isIngestAuthorized returns whether a request to the ingest endpoint carries the
+// shared IngestToken as a bearer token.
+func (sbd *State) isIngestAuthorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return sbd.Config.IngestToken != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(sbd.Config.IngestToken)) == 1
+}
+
+// ingestResponder receives an IngestBatch from a probe and feeds its updates to the
+// StateUpdater the same way this instance's own checks are. Batches are refused once
+// the competition has ended. If scoring stops while a batch is being applied, the
+// response says how many of its updates were applied.
+func (sbd *State) ingestResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isIngestAuthorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch := IngestBatch{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBytes)).Decode(&batch); err != nil {
+		http.Error(w, fmt.Sprint("Failed to decode the updates: ", err), http.StatusBadRequest)
+		return
+	}
+
+	if batch.Probe == "" || batch.Probe == replayProbe {
+		http.Error(w, fmt.Sprintf("probe must be the name of the probe, other than '%v'", replayProbe),
+			http.StatusBadRequest)
+		return
+	}
+
+	// Updates can't be applied once the competition has ended, so refuse the batch
+	// outright rather than applying part of it
+	sbd.serviceLock.RLock()
+	phase := sbd.Phase()
+	sbd.serviceLock.RUnlock()
+
+	if phase == PhaseEnded {
+		http.Error(w, "scoring is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	for applied, update := range batch.Updates {
+		details := update.Details
+		if details != "" {
+			details = fmt.Sprintf("probe %v: %v", batch.Probe, details)
+		}
+
+		// Scoring stops when the competition ends, so don't wait forever on it
+		select {
+		case sbd.updateChannel <- ServiceUpdate{
+			update.IP,
+			update.ServiceUpdate,
+			update.IsUp,
+			update.ServiceID,
+			update.Address,
+			details,
+			batch.Probe,
+			time.Duration(update.DurationMs) * time.Millisecond,
+			false,
+		}:
+		case <-time.After(5 * time.Second):
+			// The probe drops batches that fail, so say how much of this one was kept
+			http.Error(w, fmt.Sprintf("scoring stopped after %v of %v updates were applied", applied,
+				len(batch.Updates)), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StartProbe runs this instance as a probe. A probe only runs the checkers, and ships
+// their results to the central instance at ProbeTarget instead of scoring them. The
+// probe starts checking when the competition starts, and stops at StopTime.
+func (sbd *State) StartProbe() {
+	// Probes don't serve anything, so they only need privileges for ICMP
+	testPrivileges(65535, sbd.Config.PingHosts && sbd.Config.PingMethod == "icmp" && !mockChecks)
+
+	updateChannel := make(chan ServiceUpdate, 10)
+
+	shutdownSignal := make(chan bool, 1)
+	shutdownSignalMultiplier := NewMultiplier(shutdownSignal)
+	shutdownSignalGenerator := shutdownSignalMultiplier.ChannelGenerator()
+	go shutdownSignalMultiplier.Multiply()
+
+	// StopTime is only known once the start of the competition has been decided
+	sbd.startScoring()
+
+	time.AfterFunc(time.Until(sbd.Config.StopTime), func() {
+		ilog.Println("The competition duration has been reached. Shutting down the probe.")
+		shutdownSignal <- true
+		close(shutdownSignal)
+	})
+
+	shutdownPingSignal := shutdownSignalGenerator(1)
+	shutdownServiceSignal := shutdownSignalGenerator(1)
+	go func() {
+		// Nothing is checked until the competition starts
+		if !sbd.waitForStart(shutdownServiceSignal) {
+			return
+		}
+
+		sbd.WarmUp(updateChannel)
+
+		go sbd.PingChecker(updateChannel, shutdownPingSignal)
+
+		go sbd.ServiceChecker(updateChannel, shutdownServiceSignal)
+	}()
+
+	ilog.Printf("Started probe %v reporting to %v\n", sbd.Config.ProbeName, sbd.Config.ProbeTarget)
+
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go sbd.ProbeShipper(updateChannel, shutdownSignalGenerator(1), &waitGroup)
+	waitGroup.Wait()
+}
+
+// ProbeShipper is a thread that collects the results of a probe's checks and POSTs
+// them to the central instance as an IngestBatch every second. Batches that fail to
+// ship are dropped, as the central instance forgets results that stop arriving.
+func (sbd *State) ProbeShipper(updateChannel chan ServiceUpdate, shutdownShipperSignal chan interface{},
+	waitGroup *sync.WaitGroup) {
+
+	defer waitGroup.Done()
+
+	ilog.Println("Started the Probe Shipper")
+
+	var (
+		client   = http.Client{Timeout: 10 * time.Second}
+		endpoint = strings.TrimSuffix(sbd.Config.ProbeTarget, "/") + "/api/ingest"
+		batch    = IngestBatch{Probe: sbd.Config.ProbeName}
+		ticker   = time.NewTicker(1 * time.Second)
+	)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownShipperSignal:
+			ilog.Println("Shutting down the Probe Shipper")
+			return
+		case update := <-updateChannel:
+			batch.Updates = append(batch.Updates, IngestUpdate{
+				IP:            update.IP,
+				ServiceUpdate: update.ServiceUpdate,
+				IsUp:          update.IsUp,
+				ServiceID:     update.ServiceID,
+				Address:       update.Address,
+				Details:       update.Details,
+				DurationMs:    int64(update.Duration / time.Millisecond),
+			})
+		case <-ticker.C:
+			if len(batch.Updates) == 0 {
+				continue
+			}
+
+			if err := shipBatch(&client, endpoint, sbd.Config.IngestToken, batch); err != nil {
+				elog.Printf("Failed to ship %v updates to %v: %v\n", len(batch.Updates), endpoint, err)
+			} else {
+				dlog.Printf("Shipped %v updates to %v\n", len(batch.Updates), endpoint)
+			}
+
+			batch.Updates = nil
+		}
+	}
+}
+
+// shipBatch POSTs batch to endpoint, authenticated with token.
+func shipBatch(client *http.Client, endpoint, token string, batch IngestBatch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("the central instance responded with %v", response.Status)
+	}
+
+	return nil
+}
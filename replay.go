@@ -0,0 +1,154 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// readTransitionLog reads the transitions of a TransitionLog written by an earlier
+// competition, in the order they were written.
+func readTransitionLog(file string) ([]Transition, error) {
+	logFile, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer logFile.Close()
+
+	transitions := make([]Transition, 0)
+	scanner := bufio.NewScanner(logFile)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var transition Transition
+		if err := json.Unmarshal(scanner.Bytes(), &transition); err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+
+		transitions = append(transitions, transition)
+	}
+
+	return transitions, scanner.Err()
+}
+
+// The Probe of the ServiceUpdates sent by the Replayer, which probes can't be named
+const replayProbe = "replay"
+
+// lastRound returns the transitions recorded after scoring last started in transitions, and
+// the time it started. TransitionLogs from before scoring starts were marked are treated as a
+// single round that started with its first transition.
+func lastRound(transitions []Transition) ([]Transition, time.Time) {
+	for index := len(transitions) - 1; index >= 0; index-- {
+		if transitions[index].Start {
+			return transitions[index+1:], transitions[index].Time
+		}
+	}
+
+	if len(transitions) == 0 {
+		return transitions, time.Time{}
+	}
+
+	return transitions, transitions[0].Time
+}
+
+// replayUpdate returns the ServiceUpdate that has the same effect as transition. found is
+// false if the host or service of the transition isn't in the config.
+func (sbd *State) replayUpdate(transition Transition) (update ServiceUpdate, found bool) {
+	sbd.serviceLock.RLock()
+	defer sbd.serviceLock.RUnlock()
+
+	isUp := transition.To == "up"
+
+	details := ""
+	if !isUp {
+		details = "replayed failure"
+	}
+
+	for _, host := range sbd.Hosts {
+		if host.Name != transition.Host {
+			continue
+		}
+
+		if transition.Service == "" {
+			return ServiceUpdate{host.IP, false, isUp, "", host.IP, details, replayProbe, 0, true}, true
+		}
+
+		for _, service := range host.Services {
+			if service.Name == transition.Service {
+				return ServiceUpdate{host.IP, true, isUp, service.ID(), host.IP, details, replayProbe, 0, true}, true
+			}
+		}
+	}
+
+	return ServiceUpdate{}, false
+}
+
+// Replayer is used in place of the PingChecker and the ServiceChecker when the -replay flag
+// is given. It ships the transitions of the last round of the TransitionLog in replayFile
+// through updateChannel as ServiceUpdates, at the same time after the competition started as
+// they were recorded after their round started, divided by replaySpeed. The scoreboard changes
+// as if the recorded competition were happening again. Transitions of hosts and services that
+// aren't in the config are skipped.
+func (sbd *State) Replayer(updateChannel chan ServiceUpdate, shutdownReplaySignal chan interface{}) {
+	transitions, err := readTransitionLog(replayFile)
+	if err != nil {
+		elog.Printf("Failed to read the transition log %v to replay: %v\n", replayFile, err)
+		return
+	}
+
+	transitions, roundStart := lastRound(transitions)
+
+	sbd.serviceLock.RLock()
+	started := sbd.Config.StartTime
+	sbd.serviceLock.RUnlock()
+
+	ilog.Printf("Replaying %v transitions from the last round of %v at %vx speed\n", len(transitions),
+		replayFile, replaySpeed)
+
+	for _, transition := range transitions {
+		update, found := sbd.replayUpdate(transition)
+		if !found {
+			dlog.Printf("Skipping a replayed transition of an unknown host or service: %v %v",
+				transition.Host, transition.Service)
+			continue
+		}
+
+		// Keep the time from the start of the round the same as when it was recorded
+		offset := time.Duration(float64(transition.Time.Sub(roundStart)) / replaySpeed)
+		select {
+		case <-shutdownReplaySignal:
+			ilog.Println("Shutting down the Replayer")
+			return
+		case <-time.After(time.Until(started.Add(offset))):
+		}
+
+		select {
+		case <-shutdownReplaySignal:
+			ilog.Println("Shutting down the Replayer")
+			return
+		case updateChannel <- update:
+		}
+	}
+
+	ilog.Println("Finished replaying", replayFile)
+}
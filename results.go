@@ -0,0 +1,118 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// Results are the final results of the competition, which are written to ResultsJSONFile
+// when the competition ends for judging software to ingest.
+type Results struct {
+	Competition          string        `json:"competition"`
+	StartTime            time.Time     `json:"startTime"`
+	StopTime             time.Time     `json:"stopTime"`
+	OverallUptimePercent float64       `json:"overallUptimePercent"`
+	Hosts                []hostResults `json:"hosts"`
+}
+
+// hostResults are the final results of a single Host
+type hostResults struct {
+	Name                 string           `json:"name"`
+	IP                   string           `json:"ip"`
+	IsUp                 bool             `json:"isUp"`
+	UptimeSeconds        float64          `json:"uptimeSeconds"`
+	DowntimeSeconds      float64          `json:"downtimeSeconds"`
+	UptimePercent        float64          `json:"uptimePercent"`
+	Score                int64            `json:"score"`
+	UptimeScore          int64            `json:"uptimeScore"`
+	BonusPoints          int64            `json:"bonusPoints"`
+	ServiceUptimePercent float64          `json:"serviceUptimePercent"`
+	Services             []serviceResults `json:"services"`
+}
+
+// serviceResults are the final results of a single Service contained within a hostResults
+type serviceResults struct {
+	Name            string  `json:"name"`
+	IsUp            bool    `json:"isUp"`
+	UptimeSeconds   float64 `json:"uptimeSeconds"`
+	DowntimeSeconds float64 `json:"downtimeSeconds"`
+	UptimePercent   float64 `json:"uptimePercent"`
+	Score           int64   `json:"score"`
+	ChecksPassed    int64   `json:"checksPassed"`
+	ChecksTotal     int64   `json:"checksTotal"`
+}
+
+// finalResults returns the results of every host and service. Once the competition has
+// ended, the results are as of StopTime. The caller must hold at least a read lock on
+// serviceLock.
+func (sbd *State) finalResults() Results {
+	results := Results{
+		Competition:          sbd.Name,
+		StartTime:            sbd.Config.StartTime,
+		StopTime:             sbd.Config.StopTime,
+		OverallUptimePercent: sbd.OverallUptimePercent(),
+		Hosts:                make([]hostResults, 0, len(sbd.Hosts)),
+	}
+
+	for hostIndex := range sbd.Hosts {
+		host := &sbd.Hosts[hostIndex]
+
+		hostResult := hostResults{
+			Name:                 host.Name,
+			IP:                   host.IP,
+			IsUp:                 host.IsUp(),
+			UptimeSeconds:        sbd.GetUptime(host).Seconds(),
+			DowntimeSeconds:      sbd.GetDowntime(host).Seconds(),
+			UptimePercent:        sbd.UptimePercent(host),
+			Score:                sbd.HostScore(host),
+			UptimeScore:          sbd.UptimeScore(host),
+			BonusPoints:          host.BonusPoints(),
+			ServiceUptimePercent: sbd.HostServiceUptimePercent(host),
+			Services:             make([]serviceResults, 0, len(host.Services)),
+		}
+
+		for serviceIndex := range host.Services {
+			service := &host.Services[serviceIndex]
+
+			hostResult.Services = append(hostResult.Services, serviceResults{
+				Name:            service.Name,
+				IsUp:            service.IsUp(),
+				UptimeSeconds:   sbd.GetUptime(service).Seconds(),
+				DowntimeSeconds: sbd.GetDowntime(service).Seconds(),
+				UptimePercent:   sbd.UptimePercent(service),
+				Score:           sbd.ServiceScore(service),
+				ChecksPassed:    service.ChecksPassed(),
+				ChecksTotal:     service.ChecksTotal(),
+			})
+		}
+
+		results.Hosts = append(results.Hosts, hostResult)
+	}
+
+	return results
+}
+
+// writeResults writes results as JSON to ResultsJSONFile
+func (sbd *State) writeResults(results Results) error {
+	resultsJSON, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(sbd.Config.ResultsJSONFile, resultsJSON, 0644)
+}
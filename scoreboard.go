@@ -15,10 +15,17 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"math/rand"
+	"net"
 	"net/http"
-	"strconv"
-	"strings"
+	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -41,17 +48,118 @@ type State struct {
 	// Name is the name of the competition. This can be used in the web interface.
 	Name string
 
-	// The webTemplate that get's updated periodically
-	scoreboardPage []byte
+	// updateFeed receives every update once StateUpdater has applied it, for consumers
+	// such as the NATS publisher. This is nil if nothing consumes the updates.
+	updateFeed chan FeedUpdate
+
+	// influx writes service updates to InfluxDB. This is nil if InfluxDB output is not configured.
+	influx *InfluxWriter
+
+	// pingSlots holds a value for every ping that is running when MaxConcurrentPings
+	// is set, so that pings wait for a free slot. This is nil if pings aren't limited.
+	pingSlots chan struct{}
+
+	// checksInFlight holds the ID of every service whose check is running, so that
+	// a new cycle doesn't start a check on top of one that hasn't finished.
+	checksInFlight sync.Map
+
+	// hostsByIP indexes Hosts by IP so that updates can be applied quickly.
+	// This is built by startScoring.
+	hostsByIP map[string]*Host
+
+	// servicesByID indexes the services of Hosts by their ID so that updates
+	// can be applied quickly. This is built by startScoring.
+	servicesByID map[string]*Service
+
+	// updateSignal is written to when the web content needs to be re-evaluated.
+	// This is replaced whenever the scoring threads are started, so it is guarded
+	// by serviceLock.
+	updateSignal chan bool
+
+	// resetUpdaterSignal is written to when scoring is reset, so that StateUpdater forgets
+	// the results it has received toward UpThreshold and DownThreshold. This is replaced
+	// whenever the scoring threads are started, so it is guarded by serviceLock.
+	resetUpdaterSignal chan bool
+
+	// updateChannel is the channel that checks ship their ServiceUpdates over
+	// to the StateUpdater
+	updateChannel chan ServiceUpdate
+
+	// stopScoring is written to and closed to stop the scoring threads when the
+	// competition ends. This is guarded by competitionLock.
+	stopScoring chan bool
+
+	// endTimer ends the competition once StopTime is reached, and shutdownTimer
+	// shuts down servers once ShutdownAfterEnd has elapsed after it has ended.
+	// These are guarded by competitionLock.
+	endTimer      *time.Timer
+	shutdownTimer *time.Timer
+
+	// servers are the webservers to shut down once ShutdownAfterEnd has elapsed
+	servers []*http.Server
+
+	// baseName and roundDuration are the Name and CompetitionDuration from the
+	// config, so that every round starts the same way when scoring is reset.
+	baseName      string
+	roundDuration time.Duration
+
+	// Events is the log of notable things that happened to hosts and services.
+	// This is guarded by serviceLock.
+	Events []Event
+
+	// Annotations are the windows marked on the competition timeline, and
+	// excusedWindows is the number of excused windows in progress. These are
+	// guarded by serviceLock.
+	Annotations    []Annotation
+	excusedWindows int
+
+	// warmingUp is set during the WarmUpGrace after scoring starts, and warmUpTimer
+	// ends it. These are guarded by serviceLock.
+	warmingUp   bool
+	warmUpTimer *time.Timer
+
+	// initializing is set from when the scoring threads start until every service has been
+	// checked once, so that the scoreboard can show that the states aren't known yet. This
+	// is guarded by serviceLock.
+	initializing bool
+
+	// Bonuses are the manual adjustments to the scores of hosts.
+	// These are guarded by serviceLock.
+	Bonuses []Bonus
+
+	// Snapshots are the periodic records of the standings of every host.
+	// These are guarded by serviceLock.
+	Snapshots []ScoreSnapshot
+
+	// Transitions are every change in the state of a host or service, in order, and
+	// transitionLog queues the lines that TransitionLogWriter appends to the TransitionLog
+	// as they happen. These are guarded by serviceLock.
+	Transitions   []Transition
+	transitionLog chan []byte
+
+	// The webTemplate that get's updated periodically. This holds a []byte that
+	// is replaced, and never written to, so it can be read without a lock.
+	scoreboardPage atomic.Value
+
+	// The data that was last used to generate scoreboardPage
+	scoreboardData scoreboardData
+
+	// The template used to generate scoreboardPage
+	scoreboardTemplate *template.Template
 
 	// serviceLock is the RW serviceLock that will allow updating the scoreboard
 	// quickly without locking out web clients
 	serviceLock sync.RWMutex
 
-	// Template serviceLock is the serviceLock associated with the webTemplate.
+	// scoreboardPageLock guards scoreboardData and scoreboardTemplate, which are
+	// used to generate filtered pages.
 	scoreboardPageLock sync.RWMutex
 
 	adminPageLock sync.RWMutex
+
+	// competitionLock guards starting and ending the competition so that
+	// the competition can be extended while it is running or after it ended.
+	competitionLock sync.Mutex
 }
 
 // Config represents the configuration for the scoreboard.
@@ -70,14 +178,98 @@ type Config struct {
 	// Ping requests
 	PingTimeout time.Duration
 
+	// PingCheckOffset is how much later than TimeBetweenPingChecks after the WarmUp that
+	// the ping cycle starts, so that it can be kept out of phase with the service cycle.
+	PingCheckOffset time.Duration
+
+	// PingStagger represents whether pings should be spread evenly across
+	// TimeBetweenPingChecks instead of sending them all at once.
+	PingStagger bool
+
+	// MaxConcurrentPings is the most pings that can run at once. If this is zero,
+	// every host is pinged at once.
+	MaxConcurrentPings int
+
+	// PingMethod is the method used to ping hosts. Either 'icmp', 'udp', or 'tcp'.
+	PingMethod string
+
+	// PingProbePort is the port that is connected to when PingMethod is 'tcp'
+	PingProbePort string
+
+	// PingRetries is the number of times a host that doesn't respond is pinged
+	// again before it is marked as down.
+	PingRetries int
+
 	// TimeBetweenServiceChecks is the duration to wait before trying to
 	// check the services that were defined in the config file.
 	TimeBetweenServiceChecks time.Duration
 
+	// RandomizeCheckOrder represents whether services are checked in a random order
+	// every cycle instead of in config order.
+	RandomizeCheckOrder bool
+
+	// RandomizeCheckTiming represents whether each service check is delayed by a random
+	// amount within TimeBetweenServiceChecks, so the moment of a check can't be predicted.
+	RandomizeCheckTiming bool
+
+	// ServiceCheckOffset is how much later than TimeBetweenServiceChecks after the WarmUp
+	// that the service cycle starts, so that it can be kept out of phase with the ping cycle.
+	ServiceCheckOffset time.Duration
+
 	// ServiceTimeout is the duration to wait for all services (not ICMP) to
 	// respond to this program.
 	ServiceTimeout time.Duration
 
+	// TimeoutIncludesResolution represents whether resolving the hostname of a host and
+	// connecting to a service share a single ServiceTimeout, instead of each having their own.
+	TimeoutIncludesResolution bool
+
+	// TCPNoDelay represents whether Nagle's algorithm is disabled on the connections of
+	// 'tcp' service checks, so that small writes are sent right away.
+	TCPNoDelay bool
+
+	// TCPKeepAlive is the keepalive period of the connections of 'tcp' service checks.
+	// If this is zero, the default period is used, and if it is negative, keepalives
+	// are disabled.
+	TCPKeepAlive time.Duration
+
+	// CommandKillGrace is how long a host-command is given to exit after
+	// it is sent SIGTERM before it is sent SIGKILL. SIGTERM is sent this
+	// long before ServiceTimeout so that the check is still bounded by it.
+	CommandKillGrace time.Duration
+
+	// CheckHelper is a long-lived program that host-commands are sent to, one JSON line
+	// per check on its stdin, instead of starting a new process for every check. If this
+	// is empty, every host-command is run as a new process.
+	CheckHelper string
+
+	// checkHelper is the running CheckHelper. This is nil if CheckHelper is not set.
+	checkHelper *checkHelper
+
+	// ReuseConnections represents whether connections to services are kept
+	// open between checks instead of making a new connection for every check.
+	ReuseConnections bool
+
+	// connectionPool keeps the connections to services open between checks.
+	// This is nil if ReuseConnections is not set.
+	connectionPool *connectionPool
+
+	// MaxResponseBytes is the most bytes that are read from a service or a
+	// host-command's output before matching its response.
+	MaxResponseBytes int64
+
+	// LatencyBuckets are the upper bounds of the buckets that the durations of the
+	// checks of each service are counted in, in increasing order.
+	LatencyBuckets []time.Duration
+
+	// UpThreshold is the number of consecutive successful checks required
+	// before a service that is down is marked as up.
+	UpThreshold int
+
+	// DownThreshold is the number of consecutive failed checks required
+	// before a service that is up is marked as down.
+	DownThreshold int
+
 	// DefaultServiceState is the default service state for all
 	// services and hosts. If the user is wanting to test services
 	// that will all be up at the beginning of the CTF, setting this
@@ -90,20 +282,183 @@ type Config struct {
 	// ScoreboardDoc represents a custom HTML template for sending to a HTTP client.
 	ScoreboardDoc string
 
+	// StaticDirectory is a directory of files, such as a competition logo, that are
+	// served under /static/. If it has a favicon.ico, that is served as the favicon
+	// instead of the built in one. This is optional.
+	StaticDirectory string
+
+	// SortBy represents the order hosts are shown on the scoreboard in. Either 'config'
+	// for the order they are defined in, 'score', 'uptime', or 'name'.
+	SortBy string
+
+	// PageSize is the number of hosts shown on each page of the scoreboard.
+	// If this is zero, every host is shown on one page.
+	PageSize int
+
+	// GroupByCategory represents whether the scoreboard shows services grouped by
+	// their Category instead of by host.
+	GroupByCategory bool
+
+	// UptimeHalfLife is how long it takes for the weight of a moment in the weighted
+	// uptime percent to halve, so that recent downtime hurts more than early downtime.
+	// If this is zero, the weighted uptime percent isn't tracked.
+	UptimeHalfLife time.Duration
+
+	// MaxGainPerInterval is the most points a service can score between two of its
+	// checks, so that a single long interval can't leap a team up the board. If this
+	// is zero, services score every second they're up.
+	MaxGainPerInterval int64
+
+	// WarmUpGrace represents how long after scoring starts that services take the state
+	// of their checks without accruing uptime or downtime, so scoring starts from their
+	// real states instead of DefaultServiceState. This is disabled if it is zero.
+	WarmUpGrace time.Duration
+
+	// ExcludeHostDownFromService represents whether the uptime and downtime of services
+	// are paused while their host doesn't respond to pings, so that a team losing its
+	// network isn't also scored as losing every service. This only applies if PingHosts
+	// is set.
+	ExcludeHostDownFromService bool
+
+	// AccessibleColors represents whether the scoreboard should use color-blind-friendly
+	// colors and text indicators for service states.
+	AccessibleColors bool
+
+	// UnavailableBeforeStart represents whether the scoreboard is served with the status
+	// 503 Service Unavailable until StartTime, so that health checks don't consider the
+	// competition live before it starts.
+	UnavailableBeforeStart bool
+
+	// UpColor, DownColor and BackgroundColor are the CSS colors the built in scoreboard
+	// uses for services that are up, services that are down, and the page background.
+	UpColor         string
+	DownColor       string
+	BackgroundColor string
+
+	// UptimeGoodPercent is the uptime percentage at or above which an uptime is
+	// shaded as good on the scoreboard. Uptimes at or above UptimeWarnPercent
+	// but below UptimeGoodPercent are shaded as a warning, and the rest as bad.
+	UptimeGoodPercent float64
+
+	// UptimeWarnPercent is the uptime percentage at or above which an uptime is
+	// shaded as a warning instead of bad on the scoreboard.
+	UptimeWarnPercent float64
+
 	// ListenAddress represents the address to bind the HTTP server to
 	ListenAddress string
 
+	// HTTPReadTimeout, HTTPWriteTimeout, and HTTPIdleTimeout are the read, write,
+	// and idle timeouts of the webservers so that slow clients can't tie up
+	// connections indefinitely.
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+
 	// CompetitionDuration represents the duration to run the competition for.
 	CompetitionDuration time.Duration
 
+	// ShutdownAfterEnd represents the duration to keep serving the scoreboard after the
+	// competition has ended before the program exits. If this is zero, the scoreboard
+	// is served until the program is killed.
+	ShutdownAfterEnd time.Duration
+
+	// SnapshotOnEnd represents whether a static copy of the scoreboard is written
+	// to SnapshotFile when the competition ends.
+	SnapshotOnEnd bool
+
+	// SnapshotFile is the file that static copies of the scoreboard are written to.
+	// The time of each copy is added to the file name before its extension.
+	SnapshotFile string
+
+	// TransitionLog is the file that every change in the state of a host or service is
+	// appended to as JSON Lines as it happens. This is optional.
+	TransitionLog string
+
+	// HistoryLimit is the number of the most recent events and transitions served to
+	// clients when they don't ask for a number with the `limit` query parameter.
+	HistoryLimit int
+
+	// ResultsJSONFile is the file that the final results are written to as JSON when
+	// the competition ends. If this is empty, the results aren't written.
+	ResultsJSONFile string
+
+	// MetricsListenAddress is the address that /healthz and /metrics are served on by
+	// their own webserver. If this is empty, they are served with the scoreboard.
+	MetricsListenAddress string
+
+	// InfluxEndpoint represents where InfluxDB line protocol points are written when
+	// a service or host changes state. InfluxDB output is disabled if this is empty.
+	InfluxEndpoint string
+
+	// NATSURL is the NATS server that every applied update is published to, such as
+	// 'nats://127.0.0.1:4222'. Publishing is disabled if this is empty.
+	NATSURL string
+
+	// NATSSubject is the NATS subject that updates are published on
+	NATSSubject string
+
+	// OnStateChange is a command that is run whenever a service or host changes state.
+	// No command is run if this is empty.
+	OnStateChange string
+
+	// OnStateChangeTimeout represents how long the OnStateChange command may run
+	// before it is killed.
+	OnStateChangeTimeout time.Duration
+
+	// ScoreSnapshotInterval represents the duration between recording snapshots
+	// of the standings of every host. Snapshots are disabled if this is zero.
+	ScoreSnapshotInterval time.Duration
+
 	// AdminName is the username for the management account
 	AdminName string
 
 	// AdminPassword is the password for the management account
 	AdminPassword string
 
-	// StartTime represents the time that the Start() function is called which as a result
-	// represents the time the competition started.
+	// AdminLoginMessage is a message shown on the admin login page. This is optional.
+	AdminLoginMessage string
+
+	// AdminListenAddress represents the address to bind the admin panel to when
+	// AdminTLSConfig is set.
+	AdminListenAddress string
+
+	// ScoreboardAllowNets are the networks that clients must be in to view the
+	// scoreboard and its JSON API. If this is empty, every client is allowed.
+	ScoreboardAllowNets []*net.IPNet
+
+	// AdminAllowNets are the networks that clients must be in to use the admin
+	// panel. If this is empty, every client is allowed.
+	AdminAllowNets []*net.IPNet
+
+	// TrustedProxyNets are the networks of reverse proxies that are trusted to
+	// set the X-Forwarded-For header to the IP of the client they forwarded.
+	TrustedProxyNets []*net.IPNet
+
+	// AdminTLSConfig is the TLS config for the admin panel that requires clients to
+	// present a certificate signed by the admin client CA. If this is nil, the admin
+	// panel is served by the scoreboard and uses the username and password login.
+	AdminTLSConfig *tls.Config
+
+	// IngestToken is the token shared between probes and the central instance.
+	// The central instance accepts updates at /api/ingest from probes that
+	// present it. If this is empty, the ingest endpoint rejects everything.
+	IngestToken string
+
+	// ProbeTarget is the URL of the central instance that this instance ships
+	// the results of its checks to. If this is set, this instance runs as a
+	// probe and only runs the checkers.
+	ProbeTarget string
+
+	// ProbeName is the name this instance reports its results under when
+	// it runs as a probe.
+	ProbeName string
+
+	// ScheduledStart is the time the competition starts from the 'startTime:' field.
+	// If this is zero or has passed, the competition starts as soon as scoring starts.
+	ScheduledStart time.Time
+
+	// StartTime represents the time the competition started, which is when the Start()
+	// function is called, or ScheduledStart if that is later.
 	StartTime time.Time
 
 	// StopTime represents the precomputed timepoint of when the competition should end.
@@ -135,34 +490,210 @@ type UptimeTracking interface {
 // GetUptime for State returns the time that a host or service have been up and accounts for special timing
 // calculations that need to be made at the end of the competition.
 func (sbd *State) GetUptime(tracker UptimeTracking) time.Duration {
-	var duration time.Duration
-
-	if sbd.Config.CompetitionEnded {
-		duration = tracker.GetUptime(sbd.Config.StopTime)
-	} else {
-		duration = tracker.GetUptime(time.Now())
-	}
-
-	return duration
+	return tracker.GetUptime(sbd.referenceTime())
 }
 
 // GetDowntime for State returns the time that a host or service have been down and accounts for special timing
 // calculations that need to be made at the end of the competition.
 func (sbd *State) GetDowntime(tracker UptimeTracking) time.Duration {
-	var duration time.Duration
+	return tracker.GetDowntime(sbd.referenceTime())
+}
+
+// UptimePercent returns the percentage of the scored time that a host or service has been up.
+// The scored time is the time since the competition started, up until the competition ended.
+// Before the competition has started, this is zero.
+func (sbd *State) UptimePercent(tracker UptimeTracking) float64 {
+	return uptimePercent(sbd.GetUptime(tracker), sbd.GetDowntime(tracker))
+}
+
+// UptimeClass returns the CSS class that shades an uptime percentage on the
+// scoreboard. Either 'uptimeGood', 'uptimeWarn', or 'uptimeBad'.
+func (sbd *State) UptimeClass(percent float64) string {
+	if percent >= sbd.Config.UptimeGoodPercent {
+		return "uptimeGood"
+	} else if percent >= sbd.Config.UptimeWarnPercent {
+		return "uptimeWarn"
+	}
+
+	return "uptimeBad"
+}
+
+// HostServiceUptimePercent returns the percentage of the scored time that the scored services
+// of a host have been up, combined.
+func (sbd *State) HostServiceUptimePercent(host *Host) float64 {
+	var uptime, downtime time.Duration
+
+	for serviceIndex := range host.Services {
+		if !host.Services[serviceIndex].IsScored() {
+			continue
+		}
+
+		uptime += sbd.GetUptime(&host.Services[serviceIndex])
+		downtime += sbd.GetDowntime(&host.Services[serviceIndex])
+	}
+
+	return uptimePercent(uptime, downtime)
+}
+
+// hostHealthPercent returns the percentage of the scored services of a host that are up
+// right now, which is 0 if it has none. Services outside of their ActiveHours are left
+// out. This only reads host, so it can be used on a copy of a host without a lock.
+func hostHealthPercent(host *Host) float64 {
+	var up, scored int
+
+	for serviceIndex := range host.Services {
+		service := &host.Services[serviceIndex]
+		if !service.IsScored() || !service.IsActive() {
+			continue
+		}
+
+		scored++
+		if service.IsUp() {
+			up++
+		}
+	}
+
+	if scored == 0 {
+		return 0
+	}
+
+	return float64(up) / float64(scored) * 100
+}
+
+// OverallUptimePercent returns the percentage of the scored time that every scored service of
+// every host has been up, combined. The caller must hold at least a read lock on serviceLock.
+func (sbd *State) OverallUptimePercent() float64 {
+	var uptime, downtime time.Duration
+
+	for hostIndex := range sbd.Hosts {
+		host := &sbd.Hosts[hostIndex]
+
+		for serviceIndex := range host.Services {
+			if !host.Services[serviceIndex].IsScored() {
+				continue
+			}
 
+			uptime += sbd.GetUptime(&host.Services[serviceIndex])
+			downtime += sbd.GetDowntime(&host.Services[serviceIndex])
+		}
+	}
+
+	return uptimePercent(uptime, downtime)
+}
+
+// uptimePercent returns the percentage of uptime out of the total of uptime and downtime,
+// which together are the scored time.
+func uptimePercent(uptime, downtime time.Duration) float64 {
+	if scoredTime := uptime + downtime; scoredTime > 0 {
+		return float64(uptime) / float64(scoredTime) * 100
+	}
+
+	return 0
+}
+
+// HostScore returns the score of a host, which is its UptimeScore plus the points
+// of the bonuses awarded to it.
+func (sbd *State) HostScore(host *Host) int64 {
+	return sbd.UptimeScore(host) + host.BonusPoints()
+}
+
+// UptimeScore returns the points a host scored from its services. A host scores one
+// point for every second each of its services has been up, as limited by ServiceScore.
+func (sbd *State) UptimeScore(host *Host) int64 {
+	var score int64
+
+	for serviceIndex := range host.Services {
+		score += sbd.ServiceScore(&host.Services[serviceIndex])
+	}
+
+	return score
+}
+
+// sortHosts returns a copy of hosts ordered by the SortBy config option. Hosts that
+// tie are ordered by name. If SortBy is 'config', the hosts are returned as they are.
+// The standings are read from hostStates, which has the state of every host by name.
+func (sbd *State) sortHosts(hosts []Host, hostStates map[string]*HostState) []Host {
+	if sbd.Config.SortBy == "" || sbd.Config.SortBy == "config" {
+		return hosts
+	}
+
+	sorted := make([]Host, len(hosts))
+	copy(sorted, hosts)
+
+	// Compute the standings once instead of for every comparison
+	standings := make(map[string]int64, len(sorted))
+	for hostIndex := range sorted {
+		hostState, found := hostStates[sorted[hostIndex].Name]
+		if !found {
+			continue
+		}
+
+		switch sbd.Config.SortBy {
+		case "score":
+			standings[hostState.Host.Name] = hostState.Score
+		case "uptime":
+			standings[hostState.Host.Name] = int64(hostState.averageServiceUptime() / time.Second)
+		case "weighted":
+			if hostState.WeightedUptimePercent != nil {
+				standings[hostState.Host.Name] = int64(*hostState.WeightedUptimePercent * 1000)
+			}
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if standings[sorted[i].Name] != standings[sorted[j].Name] {
+			return standings[sorted[i].Name] > standings[sorted[j].Name]
+		}
+
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// CompetitionPhase is the part of the competition that is happening. Either
+// PhasePreStart, PhaseLive, or PhaseEnded.
+type CompetitionPhase string
+
+const (
+	// PhasePreStart is before the competition has started
+	PhasePreStart CompetitionPhase = "pre"
+
+	// PhaseLive is while the competition is being scored
+	PhaseLive CompetitionPhase = "live"
+
+	// PhaseEnded is after the competition has ended
+	PhaseEnded CompetitionPhase = "ended"
+)
+
+// Phase returns the part of the competition that is happening, based on StartTime and
+// whether the competition has ended. The caller must hold at least a read lock on
+// serviceLock.
+func (sbd *State) Phase() CompetitionPhase {
 	if sbd.Config.CompetitionEnded {
-		duration = tracker.GetDowntime(sbd.Config.StopTime)
-	} else {
-		duration = tracker.GetDowntime(time.Now())
+		return PhaseEnded
+	} else if time.Now().Before(sbd.Config.StartTime) {
+		return PhasePreStart
 	}
 
-	return duration
+	return PhaseLive
 }
 
-// TimeLeft returns the amount of time left for the entire competition
+// StartsIn returns the amount of time until the competition starts, which is zero
+// once it has started. The caller must hold at least a read lock on serviceLock.
+func (sbd *State) StartsIn() time.Duration {
+	if startsIn := time.Until(sbd.Config.StartTime); startsIn > 0 {
+		return startsIn
+	}
+
+	return time.Duration(0)
+}
+
+// TimeLeft returns the amount of time left for the entire competition.
+// The caller must hold at least a read lock on serviceLock because the
+// competition can be extended.
 func (sbd *State) TimeLeft() time.Duration {
-	timeRemaining := sbd.Config.CompetitionDuration - time.Now().Sub(sbd.Config.StartTime)
+	timeRemaining := sbd.Config.StopTime.Sub(sbd.referenceTime())
 
 	if timeRemaining < 0 {
 		return time.Duration(0)
@@ -171,6 +702,81 @@ func (sbd *State) TimeLeft() time.Duration {
 	return timeRemaining
 }
 
+// Elapsed returns the amount of time the competition has been running. It is zero
+// before the competition starts, and stops counting at StopTime once the competition
+// ends. The caller must hold at least a read lock on serviceLock because the
+// competition can be extended.
+func (sbd *State) Elapsed() time.Duration {
+	now := time.Now()
+	if now.After(sbd.Config.StopTime) {
+		now = sbd.Config.StopTime
+	}
+
+	if elapsed := now.Sub(sbd.Config.StartTime); elapsed > 0 {
+		return elapsed
+	}
+
+	return time.Duration(0)
+}
+
+// signalUpdate asks the WebContentUpdater to re-evaluate the web content without blocking.
+func (sbd *State) signalUpdate() {
+	select {
+	case sbd.updateSignal <- true:
+	default: // An update is already pending
+	}
+}
+
+// findService returns the host and service with the given names, or nil if there is no such service.
+// The caller must hold at least a read lock on serviceLock.
+func (sbd *State) findService(hostName, serviceName string) (*Host, *Service) {
+	for hostIndex := range sbd.Hosts {
+		host := &sbd.Hosts[hostIndex]
+
+		if host.Name != hostName {
+			continue
+		}
+
+		for serviceIndex := range host.Services {
+			if host.Services[serviceIndex].Name == serviceName {
+				return host, &host.Services[serviceIndex]
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// sortServices orders the services of each of hosts by their DisplayOrder, keeping
+// services with the same DisplayOrder in config order. The services are sorted in
+// place, so hosts should be a copy from copyHosts.
+func sortServices(hosts []Host) []Host {
+	for hostIndex := range hosts {
+		services := hosts[hostIndex].Services
+
+		sort.SliceStable(services, func(i, j int) bool {
+			return services[i].DisplayOrder < services[j].DisplayOrder
+		})
+	}
+
+	return hosts
+}
+
+// copyHosts returns a copy of Hosts that can be used without holding serviceLock.
+// The caller must hold at least a read lock on serviceLock.
+func (sbd *State) copyHosts() []Host {
+	hosts := make([]Host, len(sbd.Hosts))
+	copy(hosts, sbd.Hosts)
+
+	for i := range hosts {
+		host := &hosts[i]
+		host.Services = make([]Service, len(sbd.Hosts[i].Services))
+		copy(host.Services, sbd.Hosts[i].Services)
+	}
+
+	return hosts
+}
+
 // NewScoreboard is a helper function to return a new scoreboard
 func NewScoreboard() State {
 	return State{
@@ -181,93 +787,551 @@ func NewScoreboard() State {
 // Start is the definitive way to start the competition scoreboard. This starts a timer based off of the
 // configuration file that determines when to stop judging services. This function also starts the threads
 // used to judge services and the webserver. When competition scoring has finished, the webserver is left running
-// with the scoring data until the program is killed.
+// with the scoring data until the program is killed, or until ShutdownAfterEnd has elapsed if it is configured.
 func (sbd *State) Start() {
 
 	func() {
-		connection := strings.Split(sbd.Config.ListenAddress, ":")
-		index := 0
-		if len(connection) > 1 {
-			index = 1
+		// The listen address was validated when the config was parsed
+		port, err := listenPort(sbd.Config.ListenAddress)
+		if err != nil {
+			elog.Printf("Invalid listenAddress %q: %v\n", sbd.Config.ListenAddress, err)
+			os.Exit(1)
 		}
 
-		port, _ := strconv.Atoi(connection[index])
-
-		testPrivileges(port, sbd.Config.PingHosts)
+		testPrivileges(port, sbd.Config.PingHosts && sbd.Config.PingMethod == "icmp" && !mockChecks &&
+			replayFile == "")
 	}()
 
 	// HTTP Server
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", sbd.scoreboardResponder)
-	mux.HandleFunc("/admin", sbd.adminPanel)
+	allowScoreboard := func(handler http.HandlerFunc) http.HandlerFunc {
+		return sbd.allowFrom(sbd.Config.ScoreboardAllowNets, handler)
+	}
+
+	allowAdmin := func(handler http.HandlerFunc) http.HandlerFunc {
+		return sbd.allowFrom(sbd.Config.AdminAllowNets, handler)
+	}
+
+	mux.HandleFunc("/", allowScoreboard(sbd.scoreboardResponder))
+	mux.HandleFunc("/favicon.ico", allowScoreboard(sbd.faviconResponder))
+	if sbd.Config.StaticDirectory != "" {
+		mux.HandleFunc("/static/", allowScoreboard(sbd.staticResponder()))
+	}
+	mux.HandleFunc("/api/host/", allowScoreboard(sbd.hostDetailResponder))
+	mux.HandleFunc("/api/snapshots", allowScoreboard(sbd.snapshotResponder))
+	mux.HandleFunc("/api/uptime", allowScoreboard(sbd.uptimeResponder))
+	mux.HandleFunc("/api/config", allowScoreboard(sbd.configResponder))
+	mux.HandleFunc("/api/annotations", allowScoreboard(sbd.annotationsResponder))
+	mux.HandleFunc("/api/outages", allowScoreboard(sbd.outagesResponder))
+	mux.HandleFunc("/api/transitions", allowScoreboard(sbd.transitionsResponder))
+	mux.HandleFunc("/api/version", allowScoreboard(sbd.versionResponder))
+
+	// When admins authenticate with client certificates, the admin pages are
+	// served by their own TLS server instead of the scoreboard's server.
+	adminMux := mux
+	if sbd.Config.AdminTLSConfig != nil {
+		adminMux = http.NewServeMux()
+	}
+
+	mux.HandleFunc("/api/ingest", sbd.ingestResponder)
+
+	// The health and metrics endpoints are served by their own server when
+	// one is configured, so they can be firewalled apart from the scoreboard.
+	metricsMux := mux
+	if sbd.Config.MetricsListenAddress != "" {
+		metricsMux = http.NewServeMux()
+		metricsMux.HandleFunc("/healthz", sbd.healthzResponder)
+		metricsMux.HandleFunc("/metrics", sbd.metricsResponder)
+	} else {
+		mux.HandleFunc("/healthz", sbd.healthzResponder)
+		mux.HandleFunc("/metrics", allowScoreboard(sbd.metricsResponder))
+	}
+
+	adminMux.HandleFunc("/admin", allowAdmin(sbd.adminPanel))
+	adminMux.HandleFunc("/admin/errors", allowAdmin(sbd.adminErrorsPanel))
+	adminMux.HandleFunc("/admin/events", allowAdmin(sbd.adminEventsResponder))
+	adminMux.HandleFunc("/admin/override", allowAdmin(sbd.adminOverrideResponder))
+	adminMux.HandleFunc("/admin/override/clear", allowAdmin(sbd.adminClearOverrideResponder))
+	adminMux.HandleFunc("/admin/extend", allowAdmin(sbd.adminExtendResponder))
+	adminMux.HandleFunc("/admin/reset", allowAdmin(sbd.adminResetResponder))
+	adminMux.HandleFunc("/admin/export", allowAdmin(sbd.adminExportResponder))
+	adminMux.HandleFunc("/admin/bonus", allowAdmin(sbd.adminBonusResponder))
+	adminMux.HandleFunc("/admin/annotate", allowAdmin(sbd.adminAnnotateResponder))
+	adminMux.HandleFunc("/admin/acknowledge", allowAdmin(sbd.adminAcknowledgeResponder))
 
 	server := http.Server{
-		Addr:    sbd.Config.ListenAddress,
-		Handler: mux,
+		Addr:         sbd.Config.ListenAddress,
+		Handler:      mux,
+		ReadTimeout:  sbd.Config.HTTPReadTimeout,
+		WriteTimeout: sbd.Config.HTTPWriteTimeout,
+		IdleTimeout:  sbd.Config.HTTPIdleTimeout,
+	}
+
+	// Bind the listener before starting any of the scoring threads so that a bind
+	// failure can exit cleanly instead of leaving those threads orphaned.
+	listener, err := net.Listen("tcp", sbd.Config.ListenAddress)
+	if err != nil {
+		elog.Printf("Failed to bind the scoreboard to %v: %v\n", sbd.Config.ListenAddress, err)
+		elog.Println("Make sure no other program is using this address, or change the " +
+			"'listenAddress:' field under 'config:'")
+		os.Exit(1)
+	}
+
+	var (
+		adminServer   *http.Server
+		adminListener net.Listener
+	)
+
+	if sbd.Config.AdminTLSConfig != nil {
+		adminServer = &http.Server{
+			Addr:         sbd.Config.AdminListenAddress,
+			Handler:      adminMux,
+			TLSConfig:    sbd.Config.AdminTLSConfig,
+			ReadTimeout:  sbd.Config.HTTPReadTimeout,
+			WriteTimeout: sbd.Config.HTTPWriteTimeout,
+			IdleTimeout:  sbd.Config.HTTPIdleTimeout,
+		}
+
+		if adminListener, err = net.Listen("tcp", sbd.Config.AdminListenAddress); err != nil {
+			elog.Printf("Failed to bind the admin panel to %v: %v\n", sbd.Config.AdminListenAddress, err)
+			elog.Println("Make sure no other program is using this address, or change the " +
+				"'adminListenAddress:' field under 'config:'")
+			os.Exit(1)
+		}
+	}
+
+	var (
+		metricsServer   *http.Server
+		metricsListener net.Listener
+	)
+
+	if sbd.Config.MetricsListenAddress != "" {
+		metricsServer = &http.Server{
+			Addr:         sbd.Config.MetricsListenAddress,
+			Handler:      metricsMux,
+			ReadTimeout:  sbd.Config.HTTPReadTimeout,
+			WriteTimeout: sbd.Config.HTTPWriteTimeout,
+			IdleTimeout:  sbd.Config.HTTPIdleTimeout,
+		}
+
+		if metricsListener, err = net.Listen("tcp", sbd.Config.MetricsListenAddress); err != nil {
+			elog.Printf("Failed to bind the metrics to %v: %v\n", sbd.Config.MetricsListenAddress, err)
+			elog.Println("Make sure no other program is using this address, or change the " +
+				"'metricsListenAddress:' field under 'config:'")
+			os.Exit(1)
+		}
 	}
 
 	// Make a buffered channel to write service updates over. These updates will get read by a thread
 	// that will write serviceLock ScoreboardState
-	updateChannel := make(chan ServiceUpdate, 10)
+	sbd.updateChannel = make(chan ServiceUpdate, 10)
+
+	sbd.servers = []*http.Server{&server}
+	if adminServer != nil {
+		sbd.servers = append(sbd.servers, adminServer)
+	}
+
+	if metricsServer != nil {
+		sbd.servers = append(sbd.servers, metricsServer)
+	}
+
+	if sbd.Config.TransitionLog != "" {
+		if err := sbd.openTransitionLog(); err != nil {
+			elog.Printf("Failed to open the transition log %v: %v\n", sbd.Config.TransitionLog, err)
+			os.Exit(1)
+		}
+	}
+
+	// Remember how the competition was configured so every round starts the same way
+	sbd.baseName = sbd.Name
+	sbd.roundDuration = sbd.Config.CompetitionDuration
+
+	sbd.startScoring()
 
+	if sbd.Config.InfluxEndpoint != "" {
+		sbd.influx = NewInfluxWriter(sbd.Config.InfluxEndpoint)
+		go sbd.influx.Run()
+	}
+
+	if sbd.Config.NATSURL != "" {
+		// The publisher is the only consumer, so it reads the feed directly. This keeps
+		// the updates in order, and lets feedUpdate drop them when the feed is full.
+		sbd.updateFeed = make(chan FeedUpdate, feedBufferLength)
+
+		publisher := NewNATSPublisher(sbd.Config.NATSURL, sbd.Config.NATSSubject)
+		go publisher.Run(sbd.updateFeed)
+	}
+
+	sbd.competitionLock.Lock()
+
+	// Start the webservers before the scoring threads so that the scoreboard is reachable
+	// right away, even if the first checks are slow. It shows that it's initializing until
+	// every service has been checked once. Competition changes from the admin panel wait on
+	// competitionLock until the scoring threads have started.
+	serveError := make(chan error, 1)
+	go func() {
+		serveError <- server.Serve(listener)
+	}()
+
+	// Start the admin webserver if it is separate from the scoreboard
+	if adminServer != nil {
+		go func() {
+			// The certificates are already loaded into TLSConfig
+			if err := adminServer.ServeTLS(adminListener, "", ""); err != http.ErrServerClosed {
+				elog.Println("The admin panel stopped unexpectedly:", err)
+			}
+		}()
+	}
+
+	// Start the metrics webserver if it is separate from the scoreboard
+	if metricsServer != nil {
+		go func() {
+			if err := metricsServer.Serve(metricsListener); err != http.ErrServerClosed {
+				elog.Println("The metrics server stopped unexpectedly:", err)
+			}
+		}()
+	}
+
+	sbd.startScoringThreads()
+	sbd.endTimer = time.AfterFunc(time.Until(sbd.Config.StopTime), sbd.endCompetition)
+	sbd.competitionLock.Unlock()
+
+	ilog.Println("Started Scoreboard")
+
+	// Serve content until the webserver is shut down
+	if err := <-serveError; err != http.ErrServerClosed {
+		elog.Fatal(err)
+	}
+}
+
+// startScoringThreads starts the threads that check services, apply their updates, and
+// publish the web content. These threads run until the competition ends. Everything is
+// checked once before the checkers start so the scoreboard doesn't show stale states for
+// the first interval. The caller must hold competitionLock.
+func (sbd *State) startScoringThreads() {
 	// Make channels to write various signals over
 	shutdownSignal := make(chan bool, 1)
 	shutdownSignalMultiplier := NewMultiplier(shutdownSignal)
 	shutdownSignalGenerator := shutdownSignalMultiplier.ChannelGenerator()
 	go shutdownSignalMultiplier.Multiply()
 
+	sbd.stopScoring = shutdownSignal
+
 	updateSignal := make(chan bool, 1)
 	updateSignalMultiplier := NewMultiplier(updateSignal)
 	updateSignalGenerator := updateSignalMultiplier.ChannelGenerator()
 	go updateSignalMultiplier.Multiply()
 
-	time.AfterFunc(sbd.Config.CompetitionDuration, func() {
-		ilog.Println("The competition duration has been reached. Shutting down scoring services.")
-		shutdownSignal <- true
-		close(shutdownSignal)
+	// Only the threads started here check every service once and clear this again
+	sbd.serviceLock.Lock()
+	sbd.updateSignal = updateSignal
+	sbd.resetUpdaterSignal = make(chan bool, 1)
+	sbd.initializing = true
+	sbd.serviceLock.Unlock()
+
+	go sbd.StateUpdater(sbd.updateChannel, updateSignal, sbd.resetUpdaterSignal, shutdownSignalGenerator(1))
+
+	shutdownPingSignal := shutdownSignalGenerator(1)
+	shutdownServiceSignal := shutdownSignalGenerator(1)
+	go func() {
+		// Nothing is checked until the competition starts
+		if !sbd.waitForStart(shutdownServiceSignal) {
+			return
+		}
+
+		// A replay starts from the default states instead of checking anything
+		if replayFile == "" {
+			sbd.WarmUp(sbd.updateChannel)
+		}
+
 		sbd.serviceLock.Lock()
-		sbd.Config.CompetitionEnded = true
+		sbd.initializing = false
+		sbd.signalUpdate()
 		sbd.serviceLock.Unlock()
-	})
-
-	sbd.startScoring()
 
-	go sbd.PingChecker(updateChannel, shutdownSignalGenerator(1))
+		if replayFile != "" {
+			go sbd.Replayer(sbd.updateChannel, shutdownServiceSignal)
+			return
+		}
 
-	go sbd.ServiceChecker(updateChannel, shutdownSignalGenerator(1))
+		go sbd.PingChecker(sbd.updateChannel, shutdownPingSignal)
 
-	go sbd.StateUpdater(updateChannel, updateSignal, shutdownSignalGenerator(1))
+		go sbd.ServiceChecker(sbd.updateChannel, shutdownServiceSignal)
+	}()
 
 	go sbd.WebContentUpdater(updateSignalGenerator(1), shutdownSignalGenerator(1))
 
-	ilog.Println("Started Scoreboard")
+	go sbd.ScoreSnapshotter(shutdownSignalGenerator(1))
+
+	go sbd.ActiveHoursScheduler(shutdownSignalGenerator(1))
+}
+
+// waitForStart waits until the competition starts, which is right away unless ScheduledStart
+// hasn't passed yet. This returns false if shutdown is signaled before the competition starts.
+func (sbd *State) waitForStart(shutdown chan interface{}) bool {
+	sbd.serviceLock.RLock()
+	startsIn := sbd.StartsIn()
+	sbd.serviceLock.RUnlock()
+
+	if startsIn <= 0 {
+		return true
+	}
+
+	ilog.Println("Waiting", fmtDuration(startsIn), "for the competition to start")
+
+	select {
+	case <-time.After(startsIn):
+		return true
+	case <-shutdown:
+		return false
+	}
+}
+
+// endCompetition stops the scoring threads once StopTime has been reached. If
+// ShutdownAfterEnd is configured, the servers are shut down once it has elapsed.
+func (sbd *State) endCompetition() {
+	sbd.competitionLock.Lock()
+	defer sbd.competitionLock.Unlock()
+
+	sbd.serviceLock.Lock()
+
+	// The competition may have been extended while this was waiting on the lock
+	if sbd.Config.CompetitionEnded || time.Now().Before(sbd.Config.StopTime) {
+		sbd.serviceLock.Unlock()
+		return
+	}
+
+	sbd.Config.CompetitionEnded = true
+
+	// Uptimes are frozen at StopTime now that the competition has ended
+	var results Results
+	if sbd.Config.ResultsJSONFile != "" {
+		results = sbd.finalResults()
+	}
+
+	sbd.serviceLock.Unlock()
+
+	ilog.Println("The competition duration has been reached. Shutting down scoring services.")
+	sbd.stopScoring <- true
+	close(sbd.stopScoring)
+
+	if sbd.Config.ResultsJSONFile != "" {
+		if err := sbd.writeResults(results); err == nil {
+			ilog.Println("Wrote the final results to", sbd.Config.ResultsJSONFile)
+		} else {
+			elog.Println("Failed to write the final results:", err)
+		}
+	}
+
+	// Give judges time to grab the final numbers, then shut down the scoreboard entirely
+	if sbd.Config.ShutdownAfterEnd > 0 {
+		ilog.Printf("The scoreboard will shut down in %v\n", fmtDuration(sbd.Config.ShutdownAfterEnd))
+
+		sbd.shutdownTimer = time.AfterFunc(sbd.Config.ShutdownAfterEnd, func() {
+			ilog.Println("Shutting down the scoreboard.")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			for _, server := range sbd.servers {
+				server.Shutdown(ctx)
+			}
+		})
+	}
+}
+
+// ExtendCompetition adds extension to the time left in the competition. If the
+// competition has already ended, it is restarted to end extension from now and
+// the scoring threads are started again. The time between the end and the restart
+// counts toward the state each service was in when the competition ended.
+func (sbd *State) ExtendCompetition(extension time.Duration, admin string) {
+	sbd.competitionLock.Lock()
+	defer sbd.competitionLock.Unlock()
+
+	sbd.endTimer.Stop()
+
+	if sbd.shutdownTimer != nil {
+		sbd.shutdownTimer.Stop()
+		sbd.shutdownTimer = nil
+	}
+
+	sbd.serviceLock.Lock()
+
+	now := time.Now()
+	wasEnded := sbd.Config.CompetitionEnded
+
+	stopTime := sbd.Config.StopTime
+	if now.After(stopTime) {
+		stopTime = now
+	}
+
+	sbd.Config.StopTime = stopTime.Add(extension)
+	sbd.Config.CompetitionDuration = sbd.Config.StopTime.Sub(sbd.Config.StartTime)
+	sbd.Config.CompetitionEnded = false
+
+	sbd.logEvent("", "", fmt.Sprintf("Competition extended by %v by %v", fmtDuration(extension), admin))
+	sbd.signalUpdate()
+
+	sbd.serviceLock.Unlock()
+
+	sbd.endTimer = time.AfterFunc(sbd.Config.StopTime.Sub(now), sbd.endCompetition)
+
+	if wasEnded {
+		ilog.Println("The competition has been extended after it ended. Restarting scoring services.")
+		sbd.startScoringThreads()
+	}
+}
+
+// ResetScoring starts a new round of the competition. Every host and service is reset
+// to the default state with no uptime, downtime, or checks, overrides and snapshots
+// are cleared, and the competition runs for the configured duration from now. If
+// round is not empty, it is appended to the name of the competition. If the
+// competition had ended, the scoring threads are started again.
+func (sbd *State) ResetScoring(round, admin string) {
+	sbd.competitionLock.Lock()
+	defer sbd.competitionLock.Unlock()
+
+	sbd.endTimer.Stop()
+
+	if sbd.shutdownTimer != nil {
+		sbd.shutdownTimer.Stop()
+		sbd.shutdownTimer = nil
+	}
+
+	sbd.serviceLock.Lock()
+
+	wasEnded := sbd.Config.CompetitionEnded
+
+	for hostIndex := range sbd.Hosts {
+		for serviceIndex := range sbd.Hosts[hostIndex].Services {
+			sbd.Hosts[hostIndex].Services[serviceIndex].ClearOverride()
+		}
+	}
+
+	sbd.Config.CompetitionDuration = sbd.roundDuration
+	sbd.startScoring()
+	sbd.Snapshots = nil
+	sbd.Transitions = nil
+	sbd.Bonuses = nil
+	sbd.clearAnnotations()
+
+	sbd.Name = sbd.baseName
+	if round != "" {
+		sbd.Name = fmt.Sprintf("%v - %v", sbd.baseName, round)
+	}
+
+	sbd.logEvent("", "", fmt.Sprintf("Scoring reset for %v by %v", sbd.Name, admin))
+	sbd.signalUpdate()
+
+	// The StateUpdater of a competition that ended has stopped, and a new one is started below
+	select {
+	case sbd.resetUpdaterSignal <- true:
+	default:
+	}
+
+	sbd.serviceLock.Unlock()
 
-	// Start the webserver and serve content
-	ilog.Fatal(server.ListenAndServe())
+	sbd.endTimer = time.AfterFunc(time.Until(sbd.Config.StopTime), sbd.endCompetition)
+
+	if wasEnded {
+		ilog.Println("Scoring has been reset after the competition ended. Restarting scoring services.")
+		sbd.startScoringThreads()
+	}
 }
 
-// startScoring initializes all the times for hosts and services, and initializes the start time and end time
-// for the scoreboard.
+// startScoring initializes all the times for hosts and services, indexes the hosts and services so
+// updates can be applied to them, and initializes the start time and end time for the scoreboard.
+// The competition starts now, or at ScheduledStart if that hasn't passed yet.
+// The caller must hold a write lock on serviceLock once the scoring threads have started.
 func (sbd *State) startScoring() {
+	// Nothing is scored before the competition starts
 	newTime := time.Now()
+	if sbd.Config.ScheduledStart.After(newTime) {
+		newTime = sbd.Config.ScheduledStart
+	}
+
+	if sbd.Config.MaxConcurrentPings > 0 && sbd.pingSlots == nil {
+		sbd.pingSlots = make(chan struct{}, sbd.Config.MaxConcurrentPings)
+	}
+
+	sbd.hostsByIP = make(map[string]*Host, len(sbd.Hosts))
+	sbd.servicesByID = make(map[string]*Service)
+
+	// Hold off scoring the services until the checks have had time to set their states
+	if sbd.warmUpTimer != nil {
+		sbd.warmUpTimer.Stop()
+	}
+
+	sbd.warmingUp = sbd.Config.WarmUpGrace > 0
 
 	for hostIndex := range sbd.Hosts {
 		host := &sbd.Hosts[hostIndex]
 
 		host.previousUpdateTime = newTime
+		host.uptime = 0
+		host.downtime = 0
+		host.bonusPoints = 0
 		host.isUp = sbd.Config.DefaultServiceState
+		host.downSince = newTime
+		host.acknowledged = false
+		host.acknowledgedBy = ""
+		sbd.hostsByIP[host.IP] = host
 
 		for serviceIndex := range host.Services {
 			service := &host.Services[serviceIndex]
 
 			service.previousUpdateTime = newTime
+			service.uptime = 0
+			service.downtime = 0
+			service.paused = sbd.shouldPauseService(host, service, newTime)
 			service.isUp = sbd.Config.DefaultServiceState
+			service.downSince = newTime
+			service.acknowledged = false
+			service.acknowledgedBy = ""
+			service.decayedUptime = 0
+			service.decayedTotal = 0
+			service.decayHalfLife = sbd.Config.UptimeHalfLife
+			service.checksPassed = 0
+			service.checksTotal = 0
+			service.cappedScore = 0
+			service.scoredUptime = 0
+			service.latency = latencyHistogram{}
+			sbd.servicesByID[service.ID()] = service
 		}
 	}
 
 	sbd.Config.StartTime = newTime
 	sbd.Config.StopTime = sbd.Config.StartTime.Add(sbd.Config.CompetitionDuration)
 	sbd.Config.CompetitionEnded = false
+	sbd.recordScoringStart()
+
+	if sbd.warmingUp {
+		sbd.warmUpTimer = time.AfterFunc(sbd.Config.WarmUpGrace, sbd.endWarmUp)
+	}
+}
+
+// endWarmUp starts scoring the services once the WarmUpGrace after scoring started has passed.
+func (sbd *State) endWarmUp() {
+	sbd.serviceLock.Lock()
+	defer sbd.serviceLock.Unlock()
+
+	sbd.warmingUp = false
+	for hostIndex := range sbd.Hosts {
+		sbd.syncPaused(&sbd.Hosts[hostIndex])
+	}
+
+	ilog.Println("The warm up grace period has ended. Scoring services from their current states.")
+	sbd.signalUpdate()
+}
+
+// answeringAddress returns the address that answered update, which is empty if the
+// update is down
+func answeringAddress(update ServiceUpdate) string {
+	if !update.IsUp {
+		return ""
+	}
+
+	return update.Address
 }
 
 // StateUpdater is a thread to read service updates and write the updates to ScoreboardState. We do this so
@@ -282,8 +1346,10 @@ func (sbd *State) startScoring() {
 // The end goal of this complex locking is to minimize the time spent holding a
 // write serviceLock. however, once this function has establish a write serviceLock,
 // don't drop it because it might need to be re-established nano-seconds later.
-// This function read locks for safety reasons.
-func (sbd *State) StateUpdater(updateChannel chan ServiceUpdate, updateSignal chan bool, shutdownUpdaterSignal chan interface{}) {
+// This function read locks for safety reasons. Once resetUpdaterSignal is written to, the
+// results received before it no longer count toward UpThreshold and DownThreshold.
+func (sbd *State) StateUpdater(updateChannel chan ServiceUpdate, updateSignal chan bool, resetUpdaterSignal chan bool,
+	shutdownUpdaterSignal chan interface{}) {
 
 	// These two flags are mutually exclusive. One being set does not rely on the other
 	// which is why we have two of them, instead of expressing their logic with a single flag.
@@ -297,12 +1363,40 @@ func (sbd *State) StateUpdater(updateChannel chan ServiceUpdate, updateSignal ch
 		isReadLocked  = false // Flag to hold whether we have a read serviceLock.
 	)
 
+	// The number of consecutive matching results received for each service, keyed by
+	// service ID. Positive streaks count successes and negative streaks count
+	// failures. Only this thread touches the streaks, so they don't need a lock.
+	streaks := make(map[string]int)
+
+	// The latest result from every probe for each service and host. Only
+	// this thread touches the results, so they don't need a lock either.
+	probeResults := make(map[string]map[string]probeResult)
+
+	// writeLock trades our read serviceLock for a write serviceLock so the
+	// Scoreboard State can be changed.
+	writeLock := func() {
+		if !isWriteLocked { // If we already have a RW serviceLock, don't que another
+			sbd.serviceLock.RUnlock() // Unlock our Read serviceLock before Write Locking
+			isReadLocked = false
+			sbd.serviceLock.Lock() // WRITE LOCK
+			isWriteLocked = true
+		}
+	}
+
 	ilog.Println("Started the Service State Updater")
 
 	for {
 		// A service update that we are waiting for
 		var update ServiceUpdate
 
+		// Results from before scoring was reset don't count toward the new round
+		select {
+		case <-resetUpdaterSignal:
+			streaks = make(map[string]int)
+			probeResults = make(map[string]map[string]probeResult)
+		default:
+		}
+
 		// Test for there being another service update on the line
 		select {
 		case <-shutdownUpdaterSignal:
@@ -310,97 +1404,202 @@ func (sbd *State) StateUpdater(updateChannel chan ServiceUpdate, updateSignal ch
 			return
 		case update = <-updateChannel: // There is another update on the line
 
+			if update.Address != "" && update.Address != update.IP {
+				dlog.Printf("Received an update for %v that was answered by %v", update.IP, update.Address)
+			}
+
 			// Read-Lock to be safe.
 			if !isWriteLocked && !isReadLocked {
 				sbd.serviceLock.RLock()
 				isReadLocked = true
 			}
 
-			// Interate down to the Service or Host that needs to be updated
-			for indexOfHosts := range sbd.Hosts {
-				// Get a reference to the host
-				host := &sbd.Hosts[indexOfHosts]
-
-				if update.IP == host.IP {
-					// Found the correct host
-
-					if update.ServiceUpdate { // Is the update a service update, or an ICMP update?
-
-						// It's a service update so iterate down to the service that needs to be updated.
-						for indexOfServices := range host.Services {
-
-							// Get a reference to the service
-							service := &host.Services[indexOfServices]
-
-							if service.Name == update.ServiceName {
-								// Found the correct service
-
-								// Decide if the update contradicts the current Scoreboard State.
-								// If it does, we need to establish a Write serviceLock before changing
-								// the service state.
-								if service.isUp != update.IsUp {
-									if !isWriteLocked { // If we already have a RW serviceLock, don't que another
-										sbd.serviceLock.RUnlock() // Unlock our Read serviceLock before Write Locking
-										isReadLocked = false
-										sbd.serviceLock.Lock() // WRITE LOCK
-										isWriteLocked = true
-									}
-
-									// Update that services state
-									service.SetUp(update.IsUp)
-
-									// Debug that we received a service update
-									dlog.Printf("Received a service update for %v on %v.\n"+
-										"\tStatus: %v -> Needed to update scoreboard\n"+
-										"\tUptime: %v, Downtime: %v", service.Name,
-										host.Name, update.IsUp,
-										fmtDuration(sbd.GetUptime(service)), fmtDuration(sbd.GetDowntime(service)))
-
-								} else {
-									// Debug that we received a service update
-									dlog.Printf("Received a service update for %v on %v.\n"+
-										"\tStatus: %v -> Didn't need to update scoreboard\n"+
-										"\tUptime: %v, Downtime: %v", service.Name,
-										host.Name, update.IsUp,
-										fmtDuration(sbd.GetUptime(service)), fmtDuration(sbd.GetDowntime(service)))
-
-								}
-
-								break // We found the correct service so stop searching
-							}
-						}
-					} else {
-
-						// We are dealing with an ICMP update. We need to determine if the
-						// Scoreboard State needs to be updated.
-						if host.isUp != update.IsUp { // We need to establish a write serviceLock
-							if !isWriteLocked { // If we already have a RW serviceLock, don't que another
-								sbd.serviceLock.RUnlock()
-								isReadLocked = false
-								sbd.serviceLock.Lock() // WRITE LOCK
-								isWriteLocked = true
-							}
-
-							host.SetUp(update.IsUp)
-
-							// Debug print the service update
-							dlog.Printf("Received a ping update for %v on %v.\n"+
-								"\tStatus: %v -> Needed to update scoreboard.\n"+
-								"\tUptime: %v, Downtime: %v", host.IP,
-								host.Name, host.isUp,
-								fmtDuration(sbd.GetUptime(host)), fmtDuration(sbd.GetDowntime(host)))
-
-						} else {
-							// Debug print the service update
-							dlog.Printf("Received a ping update for %v on %v.\n"+
-								"\tStatus: %v -> Didn't need to update scoreboard.\n"+
-								"\tUptime: %v, Downtime: %v", host.IP,
-								host.Name, host.isUp,
-								fmtDuration(sbd.GetUptime(host)), fmtDuration(sbd.GetDowntime(host)))
-						}
+			// Look up the Host that needs to be updated
+			host, found := sbd.hostsByIP[update.IP]
+			if !found {
+				dlog.Println("Received an update for an unknown host:", update.IP)
+				continue
+			}
+
+			// Combine the update with the results of every other probe. This
+			// is up if any probe has recently seen it up.
+			if isUp := sbd.aggregateProbeResults(probeResults, update); isUp != update.IsUp {
+				dlog.Printf("Received a failed check for %v from probe '%v', but another probe "+
+					"recently saw it up", update.IP, update.Probe)
+
+				update.IsUp = isUp
+				update.Details = ""
+			}
+
+			if update.ServiceUpdate { // Is the update a service update, or an ICMP update?
+
+				// It's a service update so look up the service that needs to be updated.
+				service, found := sbd.servicesByID[update.ServiceID]
+				if !found {
+					dlog.Println("Received an update for an unknown service:", update.ServiceID)
+					continue
+				}
+
+				wasUp := service.isUp
+
+				// A service that requires its host to be up fails while the host
+				// doesn't respond to pings, even if the service itself answered
+				if service.RequireHostUp && !host.isUp && update.IsUp {
+					update.IsUp = false
+					update.Details = "the host is not responding to pings"
+				}
+
+				// Replayed updates are recorded transitions instead of checks, so they
+				// aren't counted and change the state right away
+				replayed := update.Replayed
+
+				// Count every check, not just the ones that change the state
+				if !replayed {
+					writeLock()
+
+					service.checksTotal++
+					if update.IsUp {
+						service.checksPassed++
+					}
+
+					service.scoreInterval(time.Now(), sbd.Config.MaxGainPerInterval)
+
+					if update.Duration > 0 {
+						service.latency.observe(update.Duration, sbd.Config.LatencyBuckets)
+					}
+				}
+
+				// Track how many results in a row have agreed with this update
+				streak := streaks[update.ServiceID]
+				if update.IsUp {
+					if streak < 0 {
+						streak = 0
+					}
+					streak++
+				} else {
+					if streak > 0 {
+						streak = 0
+					}
+					streak--
+				}
+				streaks[update.ServiceID] = streak
+
+				// Enough consecutive results must agree before the state can flip
+				thresholdMet := streak >= sbd.Config.UpThreshold ||
+					-streak >= sbd.Config.DownThreshold
+
+				// Services take the state of their checks right away while warming
+				// up, so that scoring starts from the real state of every service.
+				// Replayed transitions already met the thresholds when they were recorded.
+				if sbd.warmingUp || replayed {
+					thresholdMet = true
+				}
+
+				// Failed checks of a host that can't be reached say nothing about
+				// the service itself, so they don't change its state while it's paused
+				if sbd.Config.ExcludeHostDownFromService && !host.isUp && !update.IsUp {
+					thresholdMet = false
+				}
+
+				// Decide if the update contradicts the current Scoreboard State.
+				// If it does, we need to establish a Write serviceLock before changing
+				// the service state. Services that an admin has overridden keep
+				// their forced state no matter what the checks say.
+				if service.isUp != update.IsUp && thresholdMet && !service.overridden {
+					writeLock()
+
+					// Update that services state
+					service.SetUp(update.IsUp)
+					sbd.stateChanged(host, service, update.IsUp)
+
+					// Debug that we received a service update
+					dlog.Printf("Received a service update for %v on %v.\n"+
+						"\tStatus: %v -> Needed to update scoreboard\n"+
+						"\tUptime: %v, Downtime: %v", service.Name,
+						host.Name, update.IsUp,
+						fmtDuration(sbd.GetUptime(service)), fmtDuration(sbd.GetDowntime(service)))
+
+				} else {
+					// Debug that we received a service update
+					dlog.Printf("Received a service update for %v on %v.\n"+
+						"\tStatus: %v -> Didn't need to update scoreboard\n"+
+						"\tUptime: %v, Downtime: %v", service.Name,
+						host.Name, update.IsUp,
+						fmtDuration(sbd.GetUptime(service)), fmtDuration(sbd.GetDowntime(service)))
+
+				}
+
+				// Keep the details of the latest check for the admin panel
+				if service.lastCheckDetails != update.Details {
+					writeLock()
+
+					service.lastCheckDetails = update.Details
+				}
+
+				// Keep the address that answered so the board can show failovers.
+				// Nothing answered a failed check, so the address is cleared.
+				if activeAddress := answeringAddress(update); activeAddress != service.activeAddress &&
+					(activeAddress != "" || !update.IsUp) {
+					writeLock()
+
+					service.activeAddress = activeAddress
+				}
+
+				if sbd.updateFeed != nil {
+					sbd.feedUpdate(update, host, service, wasUp)
+				}
+			} else {
+
+				wasUp := host.isUp
+
+				// We are dealing with an ICMP update. We need to determine if the
+				// Scoreboard State needs to be updated.
+				if host.isUp != update.IsUp { // We need to establish a write serviceLock
+					writeLock()
+
+					host.SetUp(update.IsUp)
+					sbd.stateChanged(host, nil, update.IsUp)
+
+					// Services that require the host to be up go down with it
+					if !host.isUp {
+						sbd.downServicesRequiringHost(host)
 					}
 
-					break // We found the correct host, so stop searching
+					// Debug print the service update
+					dlog.Printf("Received a ping update for %v on %v.\n"+
+						"\tStatus: %v -> Needed to update scoreboard.\n"+
+						"\tUptime: %v, Downtime: %v", host.IP,
+						host.Name, host.isUp,
+						fmtDuration(sbd.GetUptime(host)), fmtDuration(sbd.GetDowntime(host)))
+
+				} else {
+					// Debug print the service update
+					dlog.Printf("Received a ping update for %v on %v.\n"+
+						"\tStatus: %v -> Didn't need to update scoreboard.\n"+
+						"\tUptime: %v, Downtime: %v", host.IP,
+						host.Name, host.isUp,
+						fmtDuration(sbd.GetUptime(host)), fmtDuration(sbd.GetDowntime(host)))
+				}
+
+				// Keep the address that answered so the board can show failovers.
+				// Nothing answered a failed check, so the address is cleared.
+				if activeAddress := answeringAddress(update); activeAddress != host.activeAddress &&
+					(activeAddress != "" || !update.IsUp) {
+					writeLock()
+
+					host.activeAddress = activeAddress
+				}
+
+				// Pause the services of a host that can't be reached, and resume
+				// them once it can be reached again
+				if sbd.needsPauseSync(host) {
+					writeLock()
+
+					sbd.syncPaused(host)
+				}
+
+				if sbd.updateFeed != nil {
+					sbd.feedUpdate(update, host, nil, wasUp)
 				}
 			}
 		default: // There is not another update on the line, so we'll wait for one
@@ -423,18 +1622,71 @@ func (sbd *State) StateUpdater(updateChannel chan ServiceUpdate, updateSignal ch
 	}
 }
 
+// stateChanged records that host, or service if it isn't nil, has changed state to isUp. The
+// transition is recorded, written to InfluxDB and passed to the OnStateChange hook, which is
+// the same no matter whether a check or an admin changed the state. The caller must hold a
+// write lock on serviceLock.
+func (sbd *State) stateChanged(host *Host, service *Service, isUp bool) {
+	serviceName := ""
+	if service != nil {
+		serviceName = service.Name
+	}
+
+	sbd.recordTransition(host.Name, serviceName, isUp)
+
+	if sbd.influx != nil {
+		sbd.influx.WriteStatus(host.Name, serviceName, isUp, time.Now())
+	}
+
+	if sbd.Config.OnStateChange != "" {
+		sbd.runStateChangeHook(host.Name, host.IP, serviceName, isUp)
+	}
+}
+
+// downServicesRequiringHost marks every Service of host that has RequireHostUp set as down,
+// unless an admin has overridden it. The caller must hold a write lock on serviceLock.
+func (sbd *State) downServicesRequiringHost(host *Host) {
+	for serviceIndex := range host.Services {
+		service := &host.Services[serviceIndex]
+
+		if !service.RequireHostUp || !service.isUp || service.overridden {
+			continue
+		}
+
+		service.SetUp(false)
+		service.lastCheckDetails = "the host is not responding to pings"
+		sbd.stateChanged(host, service, false)
+
+		dlog.Printf("%v on %v requires its host to be up, so it was marked as down", service.Name, host.Name)
+	}
+}
+
 // ServiceChecker is a thread for querying services. Results are shipped to the
-// ScoreboardStateUpdater as ServiceUpdates
+// ScoreboardStateUpdater as ServiceUpdates. The first check is made after waiting
+// TimeBetweenServiceChecks because WarmUp has already checked every service, and
+// ServiceCheckOffset on top of that to shift the phase of the cycle.
 func (sbd *State) ServiceChecker(updateChannel chan ServiceUpdate, shutdownServiceSignal chan interface{}) {
 
 	ilog.Println("Started the Service Check Provider")
 
-	totalWaitDuration := sbd.Config.TimeBetweenPingChecks / 1 * time.Second
-	currentWaitDuration := totalWaitDuration
+	// Services have their own interval, and don't wait on TimeBetweenPingChecks
+	totalWaitDuration := sbd.Config.TimeBetweenServiceChecks
+	currentWaitDuration := -sbd.Config.ServiceCheckOffset
+
+	// Closed on shutdown to cancel any randomly delayed checks that haven't started yet
+	stopChecks := make(chan struct{})
+
+	// The longest random delay before each check, which leaves every check
+	// enough time to finish before the next cycle starts
+	spread := time.Duration(0)
+	if sbd.Config.RandomizeCheckTiming {
+		spread = totalWaitDuration - sbd.Config.ServiceTimeout
+	}
 
 	for {
 		select {
 		case <-shutdownServiceSignal:
+			close(stopChecks)
 			ilog.Println("Shutting down the Service Check Provider")
 			return
 		default:
@@ -445,22 +1697,8 @@ func (sbd *State) ServiceChecker(updateChannel chan ServiceUpdate, shutdownServi
 				continue
 			}
 
-			sbd.serviceLock.RLock()
 			// Go ahead and test these bad guys before going to sleep.
-			for hostIndex := range sbd.Hosts { // Check each host
-				host := sbd.Hosts[hostIndex]
-				for serviceIndex := range host.Services { // Check each service
-					service := host.Services[serviceIndex]
-
-					// Asyncronously check services so we can check a lot of them
-					// and don't have to wait on service timeout durations
-					// which might be lengthy.
-					go service.CheckService(updateChannel,
-						host.IP, sbd.Config.ServiceTimeout)
-				}
-			}
-
-			sbd.serviceLock.RUnlock()
+			sbd.checkServices(updateChannel, &sync.WaitGroup{}, spread, stopChecks)
 
 			currentWaitDuration -= totalWaitDuration
 		}
@@ -468,17 +1706,30 @@ func (sbd *State) ServiceChecker(updateChannel chan ServiceUpdate, shutdownServi
 }
 
 // PingChecker is a thread for pinging hosts. Results are shipped to the
-// ScoreboardStateUpdater as ServiceUpdates.
+// ScoreboardStateUpdater as ServiceUpdates. The first ping is made after waiting
+// TimeBetweenPingChecks because WarmUp has already pinged every host, and
+// PingCheckOffset on top of that to shift the phase of the cycle.
 func (sbd *State) PingChecker(updateChannel chan ServiceUpdate, shutdownPingSignal chan interface{}) {
 	if sbd.Config.PingHosts { // The ping option was set
 		ilog.Println("Started the Ping Check Provider")
 
-		totalWaitDuration := sbd.Config.TimeBetweenPingChecks / 1 * time.Second
-		currentWaitDuration := totalWaitDuration
+		// TimeBetweenPingChecks is already a time.Duration, so it isn't scaled by a second
+		totalWaitDuration := sbd.Config.TimeBetweenPingChecks
+		currentWaitDuration := -sbd.Config.PingCheckOffset
+
+		// Closed on shutdown to cancel any staggered pings that haven't been sent yet
+		stopPings := make(chan struct{})
+
+		// The delay between pinging each host when pings are staggered across the interval
+		stagger := time.Duration(0)
+		if sbd.Config.PingStagger && len(sbd.Hosts) > 0 {
+			stagger = totalWaitDuration / time.Duration(len(sbd.Hosts))
+		}
 
 		for {
 			select {
 			case <-shutdownPingSignal:
+				close(stopPings)
 				ilog.Println("Shutting down the Ping Check Provider")
 				return
 			default:
@@ -489,17 +1740,162 @@ func (sbd *State) PingChecker(updateChannel chan ServiceUpdate, shutdownPingSign
 					continue
 				}
 
-				sbd.serviceLock.RLock()
-				for i := range sbd.Hosts {
-					host := sbd.Hosts[i]
-					// Asyncronously ping hosts so we don't wait full timeouts and can ping faster.
-					go host.PingHost(updateChannel, sbd.Config.PingTimeout)
-				}
-
-				sbd.serviceLock.RUnlock()
+				sbd.pingHosts(updateChannel, &sync.WaitGroup{}, stagger, stopPings)
 
 				currentWaitDuration -= totalWaitDuration
 			}
 		}
 	}
 }
+
+// WarmUp checks every service and pings every host (if configured) once, and waits
+// for the results to be shipped to the ScoreboardStateUpdater. This is done when the
+// competition starts so the scoreboard reflects reality without waiting an interval.
+func (sbd *State) WarmUp(updateChannel chan ServiceUpdate) {
+	waitGroup := sync.WaitGroup{}
+
+	ilog.Println("Checking every service once before scoring begins")
+
+	if sbd.Config.PingHosts {
+		sbd.pingHosts(updateChannel, &waitGroup, 0, nil)
+	}
+
+	sbd.checkServices(updateChannel, &waitGroup, 0, nil)
+
+	// Each check is bound by its timeout, so this won't wait forever
+	waitGroup.Wait()
+
+	ilog.Println("Finished checking every service once")
+}
+
+// checkServices asynchronously checks every service. Each check is added to waitGroup
+// and marked as done once its result has been shipped through updateChannel. If
+// RandomizeCheckOrder is set, the services are checked in a random order. If spread is
+// set, each check is delayed by a random duration up to spread. Checks that haven't
+// started yet are cancelled when stop is closed. Services whose last check is still
+// running are skipped.
+func (sbd *State) checkServices(updateChannel chan ServiceUpdate, waitGroup *sync.WaitGroup,
+	spread time.Duration, stop <-chan struct{}) {
+
+	type check struct {
+		host    Host
+		service Service
+	}
+
+	sbd.serviceLock.RLock()
+
+	checks := make([]check, 0, len(sbd.Hosts))
+	for hostIndex := range sbd.Hosts { // Check each host
+		host := sbd.Hosts[hostIndex]
+		for serviceIndex := range host.Services { // Check each service
+			// Services don't exist outside of their active hours
+			if !host.Services[serviceIndex].IsActive() {
+				continue
+			}
+
+			checks = append(checks, check{host, host.Services[serviceIndex]})
+		}
+	}
+
+	sbd.serviceLock.RUnlock()
+
+	// Teams can't time their fixes to the check window if the order changes every cycle
+	if sbd.Config.RandomizeCheckOrder {
+		rand.Shuffle(len(checks), func(i, j int) {
+			checks[i], checks[j] = checks[j], checks[i]
+		})
+	}
+
+	for _, check := range checks {
+		host, service := check.host, check.service
+
+		// Checks that outlive their interval would pile up and skew the results
+		if _, running := sbd.checksInFlight.LoadOrStore(service.ID(), true); running {
+			elog.Printf("Skipped checking %v on %v because its last check is still running. "+
+				"'serviceTimeout:' may be too close to 'serviceInterval:'\n", service.Name, host.Name)
+			continue
+		}
+
+		delay := time.Duration(0)
+		if spread > 0 {
+			delay = time.Duration(rand.Int63n(int64(spread)))
+		}
+
+		// Asyncronously check services so we can check a lot of them
+		// and don't have to wait on service timeout durations
+		// which might be lengthy.
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			defer sbd.checksInFlight.Delete(service.ID())
+
+			if delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-stop:
+					timer.Stop()
+					return
+				}
+			}
+
+			if mockChecks {
+				service.MockCheckService(updateChannel, host.IP)
+			} else {
+				service.CheckService(updateChannel,
+					host.IP, host.Addresses(), &sbd.Config)
+			}
+		}()
+	}
+}
+
+// pingHosts asynchronously pings every host. Each ping is added to waitGroup and
+// marked as done once its result has been shipped through updateChannel. If stagger
+// is set, each host is pinged stagger after the last so the pings are spread out.
+// If MaxConcurrentPings is set, pings wait for one of that many slots before they are
+// sent. Pings that haven't been sent yet are cancelled when stop is closed.
+func (sbd *State) pingHosts(updateChannel chan ServiceUpdate, waitGroup *sync.WaitGroup,
+	stagger time.Duration, stop <-chan struct{}) {
+
+	sbd.serviceLock.RLock()
+
+	for i := range sbd.Hosts {
+		host := sbd.Hosts[i]
+		delay := stagger * time.Duration(i)
+
+		// Asyncronously ping hosts so we don't wait full timeouts and can ping faster.
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			if delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-stop:
+					timer.Stop()
+					return
+				}
+			}
+
+			// Wait for a free slot so only MaxConcurrentPings pings run at once
+			if sbd.pingSlots != nil {
+				select {
+				case sbd.pingSlots <- struct{}{}:
+					defer func() { <-sbd.pingSlots }()
+				case <-stop:
+					return
+				}
+			}
+
+			if mockChecks {
+				host.MockPingHost(updateChannel)
+			} else {
+				host.PingHost(updateChannel, sbd.Config.PingTimeout,
+					sbd.Config.PingMethod, sbd.Config.PingProbePort, sbd.Config.PingRetries)
+			}
+		}()
+	}
+
+	sbd.serviceLock.RUnlock()
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// ScriptStep is a single step of a Service's conversation script. Send is
+// written to the connection, then the service's reply is read until Expect
+// matches it. Either of Send or Expect may be empty to only read or only write.
+type ScriptStep struct {
+	// Send is the string to write to the service for this step
+	Send string `yaml:"send"`
+
+	// Expect is a regular expression that must match what the service
+	// sends back for this step to pass
+	Expect string `yaml:"expect"`
+}
+
+// runScript runs each step of the Service's Script in order over conn, failing at
+// the first step whose Expect doesn't match before the connection's deadline. At
+// most maxBytes are read for each step. If the script fails, the details of the
+// failure are returned.
+func (service *Service) runScript(conn net.Conn, maxBytes int64) (bool, string) {
+	for index, step := range service.Script {
+		stringToSend := service.formatSendString(step.Send)
+
+		if len(stringToSend) > 0 {
+			if _, err := io.Copy(conn, strings.NewReader(stringToSend)); err != nil {
+				return false, fmt.Sprintf("step %v: failed to send: %v", index+1, err)
+			}
+		}
+
+		if len(step.Expect) == 0 {
+			continue
+		}
+
+		regex, err := regexp.Compile(step.Expect)
+		if err != nil {
+			return false, fmt.Sprintf("step %v: %v", index+1, err)
+		}
+
+		if matched, received, err := readUntilMatch(conn, regex.Match, maxBytes); !matched {
+			return false, fmt.Sprintf("step %v: response did not match %q (%v), received: %q",
+				index+1, step.Expect, err, tail(received, detailsTailLength))
+		}
+	}
+
+	return true, ""
+}
+
+// readUntilMatch reads from conn until what has been read matches, the connection
+// is closed or times out, or maxBytes have been read. What was read is returned
+// along with the error that stopped the read if it didn't match.
+func readUntilMatch(conn io.Reader, matches func([]byte) bool, maxBytes int64) (bool, string, error) {
+	var (
+		buffer = bytes.Buffer{}
+		reader = io.LimitReader(conn, maxBytes)
+		chunk  = make([]byte, 4096)
+	)
+
+	for {
+		read, err := reader.Read(chunk)
+		buffer.Write(chunk[:read])
+
+		if matches(buffer.Bytes()) {
+			return true, buffer.String(), nil
+		}
+
+		if err != nil {
+			return false, buffer.String(), err
+		}
+	}
+}
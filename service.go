@@ -16,11 +16,18 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
@@ -32,6 +39,15 @@ type Service struct {
 	// Name is the name of the Service this struct represents
 	Name string `yaml:"service"`
 
+	// DisplayName is the name shown for the Service on the scoreboard. This is
+	// optional, and if it's not set Name is shown instead.
+	DisplayName string `yaml:"displayName"`
+
+	// DisplayOrder is where the Service is shown among the services of its Host
+	// on the scoreboard. Services with lower values are shown first, and services
+	// with the same value are shown in config order. This is optional.
+	DisplayOrder int `yaml:"displayOrder"`
+
 	// Port is the Port that the Service is hosted on
 	Port string `yaml:"port"`
 
@@ -47,6 +63,16 @@ type Service struct {
 	// if protocol is not 'host-command'.
 	Response string `yaml:"response"`
 
+	// UseExitCode is a flag that if true, marks a 'host-command' Service as up
+	// only if Command exits with status 0 before the timeout. If Response is
+	// also set, it must match the output of Command as well. This is optional.
+	UseExitCode bool `yaml:"useExitCode"`
+
+	// ResponseDelimiter splits Response into several regular expressions.
+	// The Service is up if any of them match. This is optional, and if it's
+	// not set Response is a single regular expression.
+	ResponseDelimiter string `yaml:"responseDelimiter"`
+
 	// Protocol is the layer 4 protocol used to connect to the Service
 	// or it can be 'host-command' to signify that running a system
 	// level command should occur in the place of this program opening
@@ -54,6 +80,114 @@ type Service struct {
 	// I.E. 'tcp', 'udp', or 'host-command' to run a system command
 	Protocol string `yaml:"protocol"`
 
+	// Network is the address family used to connect to a 'tcp', 'http', or 'https'
+	// Service. Either 'tcp' for any family, 'tcp4' for only IPv4, or 'tcp6' for only
+	// IPv6. Addresses of the Host in the other family aren't checked. This is optional
+	// and defaults to 'tcp'.
+	Network string `yaml:"network"`
+
+	// SendStringFormat is the format Command is sent in when Protocol is
+	// 'tcp' or 'udp'. Either 'raw' to send Command verbatim, or
+	// 'crlf-terminated' to make sure Command ends with a CRLF.
+	// This is optional and defaults to 'raw'.
+	SendStringFormat string `yaml:"sendStringFormat"`
+
+	// Tags are labels for the Service that can be used to filter the
+	// services shown on the scoreboard. This is optional.
+	Tags []string `yaml:"tags"`
+
+	// Category is the kind of service, such as 'Web' or 'Mail', that the Service is
+	// grouped under when GroupByCategory is set. This is optional.
+	Category string `yaml:"category"`
+
+	// Method is the HTTP method used when Protocol is 'http' or 'https'.
+	// This is optional and defaults to GET.
+	Method string `yaml:"method"`
+
+	// Headers are the HTTP headers sent with the request when Protocol is
+	// 'http' or 'https'. A 'Host' header sets the virtual host requested.
+	Headers map[string]string `yaml:"headers"`
+
+	// MatchField is the part of the HTTP response that Response is matched
+	// against when Protocol is 'http' or 'https'. Either 'status', 'headers',
+	// or 'body'. This is optional and defaults to 'body'.
+	MatchField string `yaml:"matchField"`
+
+	// MatchStream is the output of the command that Response is matched against
+	// when Protocol is 'host-command'. Either 'stdout', 'stderr', or 'any' to
+	// match either of them. This is optional and defaults to 'any'.
+	MatchStream string `yaml:"matchStream"`
+
+	// ReadBytes is the exact number of bytes to read from the Service before
+	// matching Response when Protocol is 'tcp' or 'udp'. This lets fixed-length
+	// protocols that keep the connection open be checked without waiting for
+	// the timeout. This is optional, and if it's zero the response is read until
+	// the Service closes the connection or the timeout is reached. It can't be
+	// more than MaxResponseBytes.
+	ReadBytes int64 `yaml:"readBytes"`
+
+	// UDPProbe is a canned request for common UDP services when Protocol is 'udp'.
+	// Either 'ntp', 'dns', 'snmp', or 'raw'. The Service is up only if it sends a
+	// valid reply to the request. 'dns' queries the name in Command and 'snmp' uses
+	// Command as the community. This is optional and defaults to 'raw', which sends
+	// Command and matches Response as usual.
+	UDPProbe string `yaml:"udpProbe"`
+
+	// ExpectClosed is a flag that if true, inverts the check of a 'tcp' Service
+	// so that it's up when no connection can be made to Port on any address of
+	// its Host, and down if any address accepts a connection. This is for
+	// services that must not be listening. This is optional.
+	ExpectClosed bool `yaml:"expectClosed"`
+
+	// RequireHostUp is a flag that if true, marks the Service as up only while its
+	// Host also responds to pings. Checks of the Service fail while the Host is down,
+	// and the Service goes down as soon as the Host does. This requires pingHosts.
+	// This is optional.
+	RequireHostUp bool `yaml:"requireHostUp"`
+
+	// Scored is a flag that if false, shows the Service on the scoreboard for awareness
+	// without counting it towards the score or the uptime percents of its Host. This is
+	// optional and defaults to true.
+	Scored *bool `yaml:"scored"`
+
+	// ActiveHours is the window of each day that the Service exists in, such as
+	// '08:00-18:00' in local time. The Service isn't checked or scored outside of
+	// it. This is optional, and if it's not set the Service is always active.
+	ActiveHours string `yaml:"activeHours"`
+
+	// ConfirmDown is a flag that if true, checks a Service that is up once more right
+	// away when a check fails, and only reports it down if that check fails too. This
+	// keeps a single dropped connection from taking the Service down. This is optional.
+	ConfirmDown bool `yaml:"confirmDown"`
+
+	// Commands is a list of commands that are each run and matched against their
+	// own response when Protocol is 'host-command', for compound checks such as
+	// logging in and then running a query. The Service is up only if every command
+	// passes, and all of them must finish within the service timeout. When set,
+	// Command and Response are not used. This is optional.
+	Commands []CommandCheck `yaml:"commands"`
+
+	// RunAllCommands is a flag that if true, runs every one of Commands even after
+	// one of them fails, so that the details list every failing command. Otherwise
+	// the check stops at the first failure. This is optional.
+	RunAllCommands bool `yaml:"runAllCommands"`
+
+	// Env are environment variables that are set for the commands of a 'host-command'
+	// Service, on top of the environment of this program and the variables that describe
+	// the target. These override the target variables. This is optional.
+	Env map[string]string `yaml:"env"`
+
+	// WorkDir is the directory that the commands of a 'host-command' Service run in.
+	// Relative command paths are found from this directory. This is optional and
+	// defaults to the working directory of this program.
+	WorkDir string `yaml:"workdir"`
+
+	// Script is a list of steps that are run in order over the connection
+	// to the Service when Protocol is 'tcp' or 'udp', for protocols that
+	// need more than a single Command and Response. When set, Command and
+	// Response are not used. This is optional.
+	Script []ScriptStep `yaml:"script"`
+
 	// Boolean flag to represent whether the service is currently up
 	isUp bool
 
@@ -66,6 +200,61 @@ type Service struct {
 	// Variable to represent the last time the Service's service state
 	// (isUp) was updated.
 	previousUpdateTime time.Time
+
+	// A flag to represent whether the uptime and downtime of the Service are
+	// paused. While this is set, neither uptime nor downtime accrue.
+	paused bool
+
+	// A stable identifier for the Service that is assigned when the config is parsed.
+	// This is used to match ServiceUpdates to the Service.
+	id string
+
+	// A flag to represent whether an admin has forced the state of the
+	// Service. While this is set, checks do not change isUp.
+	overridden bool
+
+	// The admin that overrode the state of the Service
+	overriddenBy string
+
+	// The number of checks of the Service that passed, and the number of
+	// checks of the Service in total
+	checksPassed int64
+	checksTotal  int64
+
+	// The details of why the last check of the Service failed. This is
+	// an empty string if the last check succeeded.
+	lastCheckDetails string
+
+	// The time the Service last went down
+	downSince time.Time
+
+	// The address of the Host that answered the last check of the Service, which is
+	// empty if it failed
+	activeAddress string
+
+	// The times of day that the ActiveHours of the Service start and end
+	activeFrom  time.Duration
+	activeUntil time.Duration
+
+	// The uptime and the total scored time of the Service in seconds as of
+	// previousUpdateTime, weighted so that recent time counts more. These are
+	// only tracked if decayHalfLife is set.
+	decayedUptime float64
+	decayedTotal  float64
+	decayHalfLife time.Duration
+
+	// The points the Service scored in the intervals before its last check, and its
+	// uptime as of that check. These are only tracked if MaxGainPerInterval is set.
+	cappedScore  int64
+	scoredUptime time.Duration
+
+	// The durations of the checks of the Service, counted in LatencyBuckets
+	latency latencyHistogram
+
+	// A flag to represent whether an admin has acknowledged that the Service
+	// is down, and the admin that did. This is cleared when the Service comes up.
+	acknowledged   bool
+	acknowledgedBy string
 }
 
 // ServiceUpdate is the type used to ship updates from update functions
@@ -84,10 +273,131 @@ type ServiceUpdate struct {
 	// ICMP is up for the remote host
 	IsUp bool
 
-	// ServiceName is the name of the service to update.
+	// ServiceID is the ID of the service to update.
 	// This is used to uniquely identify services contained
 	// within hosts for the StateUpdater
-	ServiceName string
+	ServiceID string
+
+	// Address is the address of the host that responded to the check.
+	// This is an empty string if no address responded, and is
+	// used for diagnostics.
+	Address string
+
+	// Details describes why a service check failed, such as a dial error
+	// or a response that didn't match. This is an empty string if the
+	// check succeeded or if this is an ICMP update.
+	Details string
+
+	// Probe is the name of the probe that made the check. This is an
+	// empty string for checks made by this instance.
+	Probe string
+
+	// Duration is how long the service check took. This is zero if it
+	// wasn't measured, such as for ICMP updates.
+	Duration time.Duration
+
+	// Replayed is a flag that if true, represents a transition replayed by the
+	// Replayer instead of a check, which is applied right away without being counted
+	Replayed bool
+}
+
+// ID returns the stable identifier of the Service that is used to match
+// ServiceUpdates to the Service.
+func (service *Service) ID() string {
+	return service.id
+}
+
+// HasAnyTag returns whether the Service has at least one of tags.
+func (service *Service) HasAnyTag(tags []string) bool {
+	for _, tag := range tags {
+		for _, serviceTag := range service.Tags {
+			if tag == serviceTag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Label returns the name the Service is shown with on the scoreboard, which is
+// DisplayName if it's set, and Name otherwise.
+func (service *Service) Label() string {
+	if service.DisplayName != "" {
+		return service.DisplayName
+	}
+
+	return service.Name
+}
+
+// IsOverridden returns whether an admin has forced the state of the Service
+func (service *Service) IsOverridden() bool {
+	return service.overridden
+}
+
+// OverriddenBy returns the admin that forced the state of the Service, or an
+// empty string if the Service is not overridden
+func (service *Service) OverriddenBy() string {
+	return service.overriddenBy
+}
+
+// Override forces the state of the Service until ClearOverride is called.
+// While the Service is overridden, checks do not change its state.
+func (service *Service) Override(state bool, admin string) {
+	service.SetUp(state)
+	service.overridden = true
+	service.overriddenBy = admin
+}
+
+// ClearOverride lets checks change the state of the Service again.
+func (service *Service) ClearOverride() {
+	service.overridden = false
+	service.overriddenBy = ""
+}
+
+// LastCheckDetails returns the details of why the last check of the Service
+// failed, or an empty string if it succeeded.
+func (service *Service) LastCheckDetails() string {
+	return service.lastCheckDetails
+}
+
+// ChecksPassed returns the number of checks of the Service that passed
+func (service *Service) ChecksPassed() int64 {
+	return service.checksPassed
+}
+
+// ChecksTotal returns the number of checks of the Service that were made
+func (service *Service) ChecksTotal() int64 {
+	return service.checksTotal
+}
+
+// ActiveAddress returns the address of the Host that answered the last check of the
+// Service, or an empty string if no check has succeeded or the last check failed
+func (service *Service) ActiveAddress() string {
+	return service.activeAddress
+}
+
+// DownSince returns the time the Service last went down
+func (service *Service) DownSince() time.Time {
+	return service.downSince
+}
+
+// IsAcknowledged returns whether an admin has acknowledged that the Service is down
+func (service *Service) IsAcknowledged() bool {
+	return service.acknowledged
+}
+
+// AcknowledgedBy returns the admin that acknowledged that the Service is down, or
+// an empty string if it isn't acknowledged
+func (service *Service) AcknowledgedBy() string {
+	return service.acknowledgedBy
+}
+
+// Acknowledge records that admin has seen that the Service is down. The
+// acknowledgement is cleared when the Service comes up.
+func (service *Service) Acknowledge(admin string) {
+	service.acknowledged = true
+	service.acknowledgedBy = admin
 }
 
 // IsUp implements UptimeTracking for Service. This method provides
@@ -102,25 +412,66 @@ func (service *Service) IsUp() bool {
 // downtime tracking functionality.
 func (service *Service) SetUp(state bool) {
 	if service.isUp != state {
-		now := time.Now()
+		now := notBefore(time.Now(), service.previousUpdateTime)
+
+		service.accrue(now)
 		service.isUp = state
+		service.previousUpdateTime = now
 
-		if service.isUp { // Service is up so calculate how long it was down
-			service.downtime = service.downtime + now.Sub(service.previousUpdateTime)
-		} else { // Service is down, so calculate how long it was up
-			service.uptime = service.uptime + now.Sub(service.previousUpdateTime)
+		if service.isUp {
+			service.acknowledged = false
+			service.acknowledgedBy = ""
+		} else {
+			service.downSince = now
 		}
+	}
+
+}
 
+// SetPaused pauses or resumes the uptime and downtime tracking of the Service.
+// The time up until now is counted before the Service is paused.
+func (service *Service) SetPaused(paused bool) {
+	if service.paused != paused {
+		now := notBefore(time.Now(), service.previousUpdateTime)
+
+		service.accrue(now)
+		service.paused = paused
 		service.previousUpdateTime = now
 	}
+}
+
+// IsScored returns whether the Service counts towards the score and the uptime
+// percents of its Host, which it does unless Scored is set to false.
+func (service *Service) IsScored() bool {
+	return service.Scored == nil || *service.Scored
+}
 
+// IsPaused returns whether the uptime and downtime tracking of the Service is paused
+func (service *Service) IsPaused() bool {
+	return service.paused
+}
+
+// accrue adds the time since the last update to the uptime or downtime of the
+// Service, unless the Service is paused.
+func (service *Service) accrue(now time.Time) {
+	if service.paused {
+		return
+	}
+
+	service.decayedUptime, service.decayedTotal = service.decayedTimes(now)
+
+	if service.isUp { // Service is up so calculate how long it was up
+		service.uptime = service.uptime + now.Sub(service.previousUpdateTime)
+	} else { // Service is down, so calculate how long it was down
+		service.downtime = service.downtime + now.Sub(service.previousUpdateTime)
+	}
 }
 
 // GetUptime implements UptimeTracking for Service. GetUptime allows for
 // querying and returning accurate durations of uptime with respect
 // to the referenceTime provided to the function for the Service.
 func (service *Service) GetUptime(referenceTime time.Time) time.Duration {
-	if service.isUp {
+	if service.isUp && !service.paused {
 		return service.uptime + referenceTime.Sub(service.previousUpdateTime)
 	}
 
@@ -131,7 +482,7 @@ func (service *Service) GetUptime(referenceTime time.Time) time.Duration {
 // allows for querying accurate durations of downtime with respect
 // to the referenceTime provided to the function for the Service.
 func (service *Service) GetDowntime(referenceTime time.Time) time.Duration {
-	if !service.isUp {
+	if !service.isUp && !service.paused {
 		return service.downtime + referenceTime.Sub(service.previousUpdateTime)
 	}
 
@@ -140,81 +491,587 @@ func (service *Service) GetDowntime(referenceTime time.Time) time.Duration {
 
 // CheckService is a method called as a thread to check a specific service on a specific host.
 // This function checks a single service in the predefined manner contained within the
-// Service type. Results are shipped as the ServiceUpdate type via the updateChannel.
-func (service *Service) CheckService(updateChannel chan ServiceUpdate, ip string, timeout time.Duration) {
+// Service type. Each of the host's addresses is tried in turn and the service is up if
+// any of them respond. Hostnames are resolved before each check, and if none of the
+// addresses can be resolved the service is down as unresolvable, or as a DNS timeout if
+// the lookup was slow. If TimeoutIncludesResolution is set, resolving the hostname and
+// connecting to the service share a single ServiceTimeout. If ConfirmDown is set,
+// a service that was up is checked once more before it is reported down. Results are
+// shipped as the ServiceUpdate type via the updateChannel.
+func (service *Service) CheckService(updateChannel chan ServiceUpdate, ip string, addresses []string,
+	config *Config) {
+
+	checkStart := time.Now()
+	serviceUp, respondingAddress, details := service.check(ip, addresses, config)
+	duration := time.Since(checkStart)
+
+	// The failure may have been a blip on the network path, so make sure before
+	// the service goes down
+	if !serviceUp && service.ConfirmDown && service.isUp {
+		dlog.Printf("Checking %v on %v again to confirm that it is down: %v", service.Name, ip, details)
+
+		checkStart = time.Now()
+		serviceUp, respondingAddress, details = service.check(ip, addresses, config)
+		duration = time.Since(checkStart)
+	}
+
+	// Write the service update
+	updateChannel <- ServiceUpdate{
+		ip,
+		true,
+		serviceUp,
+		service.id,
+		respondingAddress,
+		details,
+		"",
+		duration,
+		false,
+	}
+}
+
+// check checks the service once as described by CheckService, and returns whether it is
+// up, the address that responded, and the details of the failure if it isn't up.
+func (service *Service) check(ip string, addresses []string, config *Config) (bool, string, string) {
 	serviceUp := false
+	respondingAddress := ""
+	details := ""
+
+	// The lookup and the connections are bounded together when the timeout includes resolution
+	ctx := context.Background()
+	if config.TimeoutIncludesResolution {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ServiceTimeout)
+		defer cancel()
+	}
 
 	if service.Protocol == "host-command" {
-		var (
-			command      = strings.Split(service.Command, " ")
-			regexToMatch = fmt.Sprint(service.Response)
-			sig          = make(chan bool, 1)
-			cmd          *exec.Cmd
-			stdout       = bytes.Buffer{}
-			stderr       = bytes.Buffer{}
-		)
-
-		if len(command) > 1 {
-			cmd = exec.Command(command[0], command[1:]...)
+		serviceUp, details = service.checkHostCommand(ip, config)
+	} else if resolved, err := resolveAddresses(ctx, addresses, config.ServiceTimeout); err != nil {
+		details = resolutionFailure(err)
+	} else if resolved = service.networkAddresses(resolved); len(resolved) == 0 {
+		details = fmt.Sprintf("the host has no addresses that can be reached with the network %v",
+			service.Network)
+	} else if service.ExpectClosed {
+		serviceUp, details = service.checkClosed(ctx, resolved, config)
+	} else {
+		failures := make([]string, 0, len(resolved))
+		for _, address := range resolved {
+			if up, failure := service.checkAddress(ctx, address, config); up {
+				serviceUp = true
+				respondingAddress = address
+				break
+			} else {
+				failures = append(failures, fmt.Sprintf("%v: %v", address, failure))
+			}
+		}
+
+		if !serviceUp {
+			details = strings.Join(failures, "; ")
+		}
+	}
+
+	return serviceUp, respondingAddress, details
+}
+
+// checkHostCommand tests a service by running Command on this host and matching
+// Response against the stdout and stderr of the command, and checking its exit
+// status if UseExitCode is set. If the Service has Commands, each of them is run
+// instead. The commands are told which host and service they are checking through
+// the environment variables from commandEnv. If the check fails, the details of the
+// failure are returned.
+func (service *Service) checkHostCommand(ip string, config *Config) (bool, string) {
+	env := service.commandEnv(ip)
+
+	if len(service.Commands) > 0 {
+		return service.checkCommands(env, config)
+	}
+
+	return service.runHostCommand(service.Command, service.Response, env, config.ServiceTimeout, config)
+}
+
+// commandEnv returns the environment that the commands of a 'host-command' Service
+// run with. This is the environment of this program with TARGET_IP set to ip, and
+// TARGET_PORT and SERVICE_NAME set from the Service, followed by the Service's Env.
+func (service *Service) commandEnv(ip string) []string {
+	env := append(os.Environ(),
+		"TARGET_IP="+ip,
+		"TARGET_PORT="+service.Port,
+		"SERVICE_NAME="+service.Name,
+	)
+
+	// Sort the variables so that the environment is the same for every check
+	names := make([]string, 0, len(service.Env))
+	for name := range service.Env {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	// Later variables override earlier ones with the same name
+	for _, name := range names {
+		env = append(env, name+"="+service.Env[name])
+	}
+
+	return env
+}
+
+// runHostCommand runs commandLine on this host with the environment env in WorkDir, and
+// matches regexToMatch against the stdout and stderr of the command, and checks its exit
+// status if UseExitCode is set. The command is sent to the CheckHelper if one is configured,
+// and is run as a new process otherwise. If the check fails, the details of the failure
+// are returned.
+func (service *Service) runHostCommand(commandLine, regexToMatch string, env []string, timeout time.Duration,
+	config *Config) (bool, string) {
+
+	execute := service.execHostCommand
+	if config.checkHelper != nil {
+		execute = service.helperHostCommand
+	}
+
+	command, err := splitCommand(commandLine)
+	if err != nil {
+		return false, fmt.Sprint("invalid command: ", err)
+	}
+
+	result, err := execute(command, env, timeout, config)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	stdout, stderr := result.stdout, result.stderr
+
+	exitStatus := "exit status 0"
+	if result.exitErr != nil {
+		exitStatus = result.exitErr.Error()
+	}
+
+	if service.UseExitCode {
+		if result.exitErr != nil {
+			return false, fmt.Sprintf("command failed (%v), stderr: %q",
+				exitStatus, tail(string(stderr), detailsTailLength))
+		}
+
+		// The exit code alone decides the result unless a response must match too
+		if len(regexToMatch) == 0 {
+			return true, ""
+		}
+	}
+
+	matchedStdout := service.MatchStream != "stderr" && service.matches(regexToMatch, stdout)
+	matchedStderr := service.MatchStream != "stdout" && service.matches(regexToMatch, stderr)
+	if matchedStdout || matchedStderr {
+		return true, ""
+	}
+
+	if service.MatchStream == "stdout" || service.MatchStream == "stderr" {
+		return false, fmt.Sprintf("%v did not match %q (%v), stderr: %q", service.MatchStream,
+			regexToMatch, exitStatus, tail(string(stderr), detailsTailLength))
+	}
+
+	return false, fmt.Sprintf("response did not match %q (%v), stderr: %q",
+		regexToMatch, exitStatus, tail(string(stderr), detailsTailLength))
+}
+
+// commandResult is the output of a host-command that ran, and how it exited
+type commandResult struct {
+	stdout []byte
+	stderr []byte
+
+	// exitErr is the error the command exited with, which is nil if it exited with status 0
+	exitErr error
+}
+
+// execHostCommand runs command as a new process with the environment env in WorkDir, and
+// returns its output and the error it exited with. If the command does not finish before
+// timeout, its process group is sent SIGTERM, then SIGKILL once CommandKillGrace has
+// passed. An error is returned if the command couldn't be started.
+func (service *Service) execHostCommand(command []string, env []string, timeout time.Duration,
+	config *Config) (commandResult, error) {
+
+	var (
+		done   = make(chan struct{})
+		cmd    *exec.Cmd
+		stdout = limitedBuffer{limit: config.MaxResponseBytes}
+		stderr = limitedBuffer{limit: config.MaxResponseBytes}
+	)
+
+	cmd = exec.Command(command[0], command[1:]...)
+
+	cmd.Env = env
+	cmd.Dir = service.WorkDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// Run the command in its own process group so that anything it starts
+	// is stopped along with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return commandResult{}, fmt.Errorf("failed to start command: %v", err)
+	}
+
+	// signalGroup sends signal to the command's process group if it hasn't exited yet
+	signalGroup := func(signal syscall.Signal) {
+		select {
+		case <-done:
+			return
+		default:
+			syscall.Kill(-cmd.Process.Pid, signal)
+		}
+	}
+
+	terminateTimer := time.AfterFunc(timeout-config.CommandKillGrace, func() {
+		signalGroup(syscall.SIGTERM)
+	})
+	killTimer := time.AfterFunc(timeout, func() {
+		signalGroup(syscall.SIGKILL)
+	})
+
+	waitErr := cmd.Wait()
+	close(done)
+	terminateTimer.Stop()
+	killTimer.Stop()
+
+	return commandResult{stdout.Bytes(), stderr.Bytes(), waitErr}, nil
+}
+
+// checkClosed tests a service that must not be listening by trying to connect to
+// Port on every address of its host. The service is up if every connection is
+// refused or times out. Any other error, such as no route to the address, doesn't
+// show that the port is closed, so it fails the check. The addresses that accepted
+// the connection or failed otherwise are returned as the details of the failure.
+// When TimeoutIncludesResolution is set, ctx is done once the time shared with
+// resolving the hostname runs out. A connection that can't be attempted because
+// of that, or that times out after less than half of ServiceTimeout because of
+// it, fails the check instead of counting as closed.
+func (service *Service) checkClosed(ctx context.Context, addresses []string, config *Config) (bool, string) {
+	dialer := net.Dialer{Timeout: config.ServiceTimeout}
+	noTimeLeft := "no time left to connect after resolving the hostname"
+
+	failures := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if ctx.Err() != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", address, noTimeLeft))
+			continue
+		}
+
+		dialStart := time.Now()
+		conn, err := dialer.DialContext(ctx, service.dialNetwork(), net.JoinHostPort(address, service.Port))
+		if err != nil && ctx.Err() != nil && time.Since(dialStart) < config.ServiceTimeout/2 {
+			failures = append(failures, fmt.Sprintf("%v: %v", address, noTimeLeft))
+			continue
+		}
+
+		if err == nil {
+			conn.Close()
+			failures = append(failures, fmt.Sprintf("%v: port %v is open", address, service.Port))
+			continue
+		}
+
+		var netErr net.Error
+		if !errors.Is(err, syscall.ECONNREFUSED) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+			failures = append(failures, fmt.Sprintf("%v: %v", address, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return false, strings.Join(failures, "; ")
+	}
+
+	return true, ""
+}
+
+// checkAddress tests a service on a single address of its host using the
+// service's Protocol. The connection to the service must be made before ctx is done.
+// If the check fails, the details of the failure are returned.
+func (service *Service) checkAddress(ctx context.Context, address string, config *Config) (bool, string) {
+	if service.Protocol == "http" || service.Protocol == "https" {
+		return service.checkHTTP(ctx, address, config)
+	}
+
+	return service.checkSocket(ctx, address, config)
+}
+
+// checkSocket tests a service by opening a socket to it, optionally writing
+// Command to it, and matching Response against what the service sends back.
+// If the Service has a Script, the Script is run over the socket instead.
+// If the check fails, the details of the failure are returned.
+func (service *Service) checkSocket(ctx context.Context, address string, config *Config) (bool, string) {
+	var (
+		timeout = config.ServiceTimeout
+		poolKey = service.id + "|" + address
+		conn    net.Conn
+	)
+
+	// Only checks that start by writing Command over TCP can reuse their
+	// connection. Anything else expects what a new connection is greeted with.
+	reuse := config.connectionPool != nil && service.Protocol == "tcp" &&
+		len(service.Script) == 0 && len(service.Command) > 0
+
+	if reuse {
+		conn = config.connectionPool.take(poolKey)
+	}
+
+	if conn == nil {
+		newConn, err := dialService(ctx, service.dialNetwork(), net.JoinHostPort(address, service.Port), config)
+		if err != nil {
+			return false, err.Error()
+		}
+
+		conn = newConn
+	}
+
+	// Keep the connection for the next check if the check left it usable,
+	// otherwise it's replaced with a new connection next time.
+	keepConnection := false
+	defer func() {
+		if keepConnection {
+			conn.SetDeadline(time.Time{})
+			config.connectionPool.put(poolKey, conn)
 		} else {
-			cmd = exec.Command(command[0])
+			conn.Close()
 		}
+	}()
 
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
+	conn.SetDeadline(time.Now().Add(timeout))
 
-		cmd.Start()
+	if len(service.Script) > 0 {
+		return service.runScript(conn, config.MaxResponseBytes)
+	}
 
-		time.AfterFunc(timeout, func() {
-			select {
-			case <-sig:
-				return
-			default:
-				if cmd.Process != nil {
-					syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
-				}
-			}
-		})
+	if service.UDPProbe != "" && service.UDPProbe != "raw" {
+		return service.runUDPProbe(conn, config.MaxResponseBytes)
+	}
+
+	stringToSend := service.formatSendString(service.Command)
+	regexToMatch := fmt.Sprint(service.Response)
+
+	var writeErr error
+	if len(stringToSend) > 0 {
+		_, writeErr = io.Copy(conn, strings.NewReader(stringToSend)) // Write what we need to write.
+	}
+
+	// No sense of even bothering to read the response if we aren't
+	// going to do anything with it.
+	if len(regexToMatch) == 0 {
+		keepConnection = reuse && writeErr == nil
+		return true, ""
+	}
+
+	buffer := bytes.Buffer{}
 
-		cmd.Wait()
-		sig <- true
+	if service.ReadBytes > 0 {
+		// Read exactly ReadBytes so fixed-length responses don't wait on the deadline
+		response := make([]byte, service.ReadBytes)
+		read, err := io.ReadFull(conn, response)
+		buffer.Write(response[:read])
 
-		foundInStdout, _ := regexp.Match(regexToMatch, stdout.Bytes())
-		foundInStderr, _ := regexp.Match(regexToMatch, stderr.Bytes())
+		if err == nil && service.matchesResponse(buffer.Bytes()) {
+			keepConnection = reuse && writeErr == nil
+			return true, ""
+		}
+	} else if reuse {
+		// The service won't close a connection that is being reused, so stop
+		// reading as soon as the response matches instead of at the deadline.
+		matched, received, _ := readUntilMatch(conn, service.matchesResponse, config.MaxResponseBytes)
+		if matched {
+			keepConnection = writeErr == nil
+			return true, ""
+		}
 
-		serviceUp = foundInStdout || foundInStderr
+		buffer.WriteString(received)
 	} else {
-		if conn, err := net.DialTimeout(service.Protocol,
-			fmt.Sprintf("%v:%v", ip, service.Port), timeout); err == nil {
+		io.Copy(&buffer, io.LimitReader(conn, config.MaxResponseBytes)) // Read the response
 
-			stringToSend := fmt.Sprint(service.Command)
-			regexToMatch := fmt.Sprint(service.Response)
+		if service.matchesResponse(buffer.Bytes()) {
+			return true, ""
+		}
+	}
 
-			conn.SetDeadline(time.Now().Add(timeout))
+	return false, fmt.Sprintf("response did not match %q, received: %q",
+		regexToMatch, tail(buffer.String(), detailsTailLength))
+}
 
-			if len(stringToSend) > 0 {
-				io.Copy(conn, strings.NewReader(stringToSend)) // Write what we need to write.
-			}
+// dialNetwork returns the network that net.Dial connects to the Service with, which is
+// its Network if it's set, and otherwise its Protocol, or 'tcp' for 'http' and 'https'.
+func (service *Service) dialNetwork() string {
+	if service.Network != "" {
+		return service.Network
+	} else if service.Protocol == "http" || service.Protocol == "https" {
+		return "tcp"
+	}
 
-			// No sense of even bothering to read the response if we aren't
-			// going to do anything with it.
-			if len(regexToMatch) > 0 {
-				buffer := bytes.Buffer{}
-				io.Copy(&buffer, conn) // Read the response
-				serviceUp, _ = regexp.Match(regexToMatch, buffer.Bytes())
-			} else {
-				serviceUp = true
+	return service.Protocol
+}
+
+// networkAddresses returns the addresses that can be reached with the Service's Network.
+// Every address can be reached unless Network is 'tcp4' or 'tcp6'.
+func (service *Service) networkAddresses(addresses []string) []string {
+	if service.Network != "tcp4" && service.Network != "tcp6" {
+		return addresses
+	}
+
+	reachable := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip != nil && (ip.To4() != nil) == (service.Network == "tcp4") {
+			reachable = append(reachable, address)
+		}
+	}
+
+	return reachable
+}
+
+// dialService connects to a service at address. For TCP, the connection uses the
+// keepalive period of TCPKeepAlive and has Nagle's algorithm set by TCPNoDelay. The
+// connection must be made within ServiceTimeout and before ctx is done.
+func dialService(ctx context.Context, network, address string, config *Config) (net.Conn, error) {
+	dialer := net.Dialer{
+		Timeout:   config.ServiceTimeout,
+		KeepAlive: config.TCPKeepAlive,
+	}
+
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetNoDelay(config.TCPNoDelay)
+	}
+
+	return conn, nil
+}
+
+// matchesResponse returns whether response matches Response, or any of the
+// regular expressions in Response if it's split by ResponseDelimiter.
+func (service *Service) matchesResponse(response []byte) bool {
+	return service.matches(service.Response, response)
+}
+
+// matches returns whether response matches expected, or any of the regular
+// expressions in expected if it's split by ResponseDelimiter. Empty expressions
+// from a leading, trailing, or doubled delimiter are skipped since they would
+// match any response.
+func (service *Service) matches(expected string, response []byte) bool {
+	patterns := []string{expected}
+	if service.ResponseDelimiter != "" && expected != "" {
+		patterns = patterns[:0]
+		for _, pattern := range strings.Split(expected, service.ResponseDelimiter) {
+			if pattern != "" {
+				patterns = append(patterns, pattern)
 			}
+		}
+	}
 
-			conn.Close()
+	for _, pattern := range patterns {
+		if matched, _ := regexp.Match(pattern, response); matched {
+			return true
 		}
 	}
 
-	// Write the service update
-	updateChannel <- ServiceUpdate{
-		ip,
-		true,
-		serviceUp,
-		service.Name,
+	return false
+}
+
+// formatSendString formats a string to write to the Service according to
+// its SendStringFormat
+func (service *Service) formatSendString(stringToSend string) string {
+	if len(stringToSend) > 0 && service.SendStringFormat == "crlf-terminated" &&
+		!strings.HasSuffix(stringToSend, "\r\n") {
+		stringToSend = strings.TrimSuffix(stringToSend, "\n") + "\r\n"
+	}
+
+	return stringToSend
+}
+
+// checkHTTP tests a service by sending a HTTP request constructed from the
+// Service's Method, Command (used as the request path), and Headers. Response
+// is matched against the part of the HTTP response selected by MatchField.
+// If the check fails, the details of the failure are returned.
+func (service *Service) checkHTTP(ctx context.Context, address string, config *Config) (bool, string) {
+	var (
+		timeout      = config.ServiceTimeout
+		method       = service.Method
+		path         = service.Command
+		regexToMatch = fmt.Sprint(service.Response)
+		toMatch      = bytes.Buffer{}
+	)
+
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method,
+		fmt.Sprintf("%v://%v%v", service.Protocol, net.JoinHostPort(address, service.Port), path), nil)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	for header, value := range service.Headers {
+		if strings.EqualFold(header, "Host") {
+			request.Host = value
+		} else {
+			request.Header.Set(header, value)
+		}
+	}
+
+	transport := &http.Transport{
+		// Competition services commonly use self-signed certificates
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		// Every check makes a new connection unless connections are reused
+		DisableKeepAlives: true,
+	}
+
+	if config.connectionPool != nil {
+		transport = config.connectionPool.transport(service.id)
+	}
+
+	client := http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// Score the response we were given, not the one we would be redirected to
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	defer func() {
+		// The body has to be read to the end for the connection to be reused
+		if config.connectionPool != nil {
+			io.Copy(ioutil.Discard, io.LimitReader(response.Body, config.MaxResponseBytes))
+		}
+
+		response.Body.Close()
+	}()
+
+	// No sense of even bothering to read the response if we aren't
+	// going to do anything with it.
+	if len(regexToMatch) == 0 {
+		return true, ""
+	}
+
+	switch service.MatchField {
+	case "status":
+		toMatch.WriteString(response.Status)
+	case "headers":
+		response.Header.Write(&toMatch)
+	default:
+		io.Copy(&toMatch, io.LimitReader(response.Body, config.MaxResponseBytes))
+	}
+
+	if service.matchesResponse(toMatch.Bytes()) {
+		return true, ""
+	}
+
+	return false, fmt.Sprintf("response did not match %q (%v), received: %q",
+		regexToMatch, response.Status, tail(toMatch.String(), detailsTailLength))
 }
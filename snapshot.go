@@ -0,0 +1,111 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+)
+
+// ScoreSnapshot is a record of the standings of every host at a point in time.
+// Snapshots are taken periodically so that ties can be broken by looking at
+// the standings at a specific point during the competition.
+type ScoreSnapshot struct {
+	// Time is the time that the snapshot was taken
+	Time time.Time `json:"time"`
+
+	// Hosts are the standings of each host when the snapshot was taken
+	Hosts []HostSnapshot `json:"hosts"`
+
+	// Annotations are the windows that were marked on the timeline when the snapshot
+	// was taken, so the standings can be read along with any excused downtime
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// HostSnapshot is the standing of a single Host contained within a ScoreSnapshot.
+type HostSnapshot struct {
+	// Name is the name of the host
+	Name string `json:"name"`
+
+	// ServiceUptime is the cumulative uptime of all of the host's services
+	ServiceUptime string `json:"serviceUptime"`
+
+	// ServiceDowntime is the cumulative downtime of all of the host's services
+	ServiceDowntime string `json:"serviceDowntime"`
+
+	// Score is the score of the host
+	Score int64 `json:"score"`
+}
+
+// takeSnapshot records the current standings of every host into Snapshots.
+// The caller must hold a write lock on serviceLock.
+func (sbd *State) takeSnapshot() {
+	snapshot := ScoreSnapshot{
+		Time:        time.Now(),
+		Hosts:       make([]HostSnapshot, 0, len(sbd.Hosts)),
+		Annotations: sbd.copyAnnotations(),
+	}
+
+	for hostIndex := range sbd.Hosts {
+		host := &sbd.Hosts[hostIndex]
+
+		var uptime, downtime time.Duration
+		for serviceIndex := range host.Services {
+			if !host.Services[serviceIndex].IsScored() {
+				continue
+			}
+
+			uptime += sbd.GetUptime(&host.Services[serviceIndex])
+			downtime += sbd.GetDowntime(&host.Services[serviceIndex])
+		}
+
+		snapshot.Hosts = append(snapshot.Hosts, HostSnapshot{
+			Name:            host.Name,
+			ServiceUptime:   fmtDuration(uptime),
+			ServiceDowntime: fmtDuration(downtime),
+			Score:           sbd.HostScore(host),
+		})
+	}
+
+	sbd.Snapshots = append(sbd.Snapshots, snapshot)
+}
+
+// ScoreSnapshotter is a thread for periodically recording the standings of every host.
+// A final snapshot is taken when the competition ends.
+func (sbd *State) ScoreSnapshotter(shutdownSnapshotSignal chan interface{}) {
+	if sbd.Config.ScoreSnapshotInterval <= 0 {
+		return
+	}
+
+	ilog.Println("Started the Score Snapshotter")
+
+	ticker := time.NewTicker(sbd.Config.ScoreSnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownSnapshotSignal:
+			sbd.serviceLock.Lock()
+			sbd.takeSnapshot()
+			sbd.serviceLock.Unlock()
+
+			ilog.Println("Shutting down the Score Snapshotter")
+			return
+		case <-ticker.C:
+			sbd.serviceLock.Lock()
+			sbd.takeSnapshot()
+			sbd.serviceLock.Unlock()
+		}
+	}
+}
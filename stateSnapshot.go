@@ -0,0 +1,226 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+)
+
+// StateSnapshot is a copy of the state of the competition that is taken under a single read
+// lock on serviceLock, so that the scoreboard and the APIs can read every host and service
+// without holding the lock. Uptimes and scores are all measured up to Time, so they agree
+// with each other. A StateSnapshot must not be written to.
+type StateSnapshot struct {
+	// Time is the time that uptimes and scores are measured up to, which is StopTime
+	// once the competition has ended.
+	Time time.Time
+
+	Name             string
+	Phase            CompetitionPhase
+	CompetitionEnded bool
+	Initializing     bool
+	StartTime        time.Time
+	StopTime         time.Time
+	TimeLeft         time.Duration
+	StartsIn         time.Duration
+	Elapsed          time.Duration
+
+	OverallUptimePercent float64
+	Annotations          []Annotation
+	Hosts                []HostState
+}
+
+// HostState is the state of a single Host contained within a StateSnapshot
+type HostState struct {
+	// Host is a copy of the Host
+	Host Host
+
+	Uptime               time.Duration
+	Downtime             time.Duration
+	UptimePercent        float64
+	Score                int64
+	UptimeScore          int64
+	Bonuses              []Bonus
+	ServiceUptimePercent float64
+
+	// HealthPercent is the percentage of the scored services of Host that are up
+	HealthPercent float64
+
+	// WeightedUptimePercent is only set if UptimeHalfLife is set
+	WeightedUptimePercent *float64
+
+	// Services are the states of the services of Host, in the same order
+	Services []ServiceState
+}
+
+// ServiceState is the state of a single Service contained within a HostState
+type ServiceState struct {
+	// Service points to the copy of the Service in the HostState's Host
+	Service *Service
+
+	Uptime        time.Duration
+	Downtime      time.Duration
+	UptimePercent float64
+
+	// WeightedUptimePercent is only set if UptimeHalfLife is set
+	WeightedUptimePercent *float64
+
+	// Latency is a copy of the durations of the checks of the Service
+	Latency latencyHistogram
+}
+
+// Snapshot returns a StateSnapshot of the current state of the competition
+func (sbd *State) Snapshot() StateSnapshot {
+	sbd.serviceLock.RLock()
+	defer sbd.serviceLock.RUnlock()
+
+	snapshot := StateSnapshot{
+		Time:             sbd.referenceTime(),
+		Name:             sbd.Name,
+		Phase:            sbd.Phase(),
+		CompetitionEnded: sbd.Config.CompetitionEnded,
+		Initializing:     sbd.initializing,
+		StartTime:        sbd.Config.StartTime,
+		StopTime:         sbd.Config.StopTime,
+		TimeLeft:         sbd.TimeLeft(),
+		StartsIn:         sbd.StartsIn(),
+		Elapsed:          sbd.Elapsed(),
+		Annotations:      sbd.copyAnnotations(),
+	}
+
+	var overallUptime, overallDowntime time.Duration
+
+	hosts := sbd.copyHosts()
+	snapshot.Hosts = make([]HostState, len(hosts))
+	for hostIndex := range hosts {
+		hostState := &snapshot.Hosts[hostIndex]
+		hostState.Host = hosts[hostIndex]
+
+		host := &hostState.Host
+		hostState.Uptime = host.GetUptime(snapshot.Time)
+		hostState.Downtime = host.GetDowntime(snapshot.Time)
+		hostState.UptimePercent = uptimePercent(hostState.Uptime, hostState.Downtime)
+		hostState.Bonuses = sbd.hostBonuses(host.Name)
+		hostState.Services = make([]ServiceState, len(host.Services))
+
+		var (
+			serviceUptime, serviceDowntime time.Duration
+			decayedUptime, decayedTotal    float64
+		)
+
+		for serviceIndex := range host.Services {
+			service := &host.Services[serviceIndex]
+
+			serviceState := ServiceState{
+				Service:  service,
+				Uptime:   service.GetUptime(snapshot.Time),
+				Downtime: service.GetDowntime(snapshot.Time),
+				Latency:  service.latency.copy(),
+			}
+			serviceState.UptimePercent = uptimePercent(serviceState.Uptime, serviceState.Downtime)
+
+			uptime, total := service.decayedTimes(snapshot.Time)
+			serviceState.WeightedUptimePercent = sbd.trackedPercent(decayedPercent(uptime, total))
+
+			hostState.Services[serviceIndex] = serviceState
+			hostState.UptimeScore += service.Score(snapshot.Time, sbd.Config.MaxGainPerInterval)
+
+			// Services that aren't scored are left out of the uptime percents of the host
+			if !service.IsScored() {
+				continue
+			}
+
+			serviceUptime += serviceState.Uptime
+			serviceDowntime += serviceState.Downtime
+			decayedUptime += uptime
+			decayedTotal += total
+		}
+
+		hostState.Score = hostState.UptimeScore + host.BonusPoints()
+		hostState.ServiceUptimePercent = uptimePercent(serviceUptime, serviceDowntime)
+		hostState.HealthPercent = hostHealthPercent(host)
+		hostState.WeightedUptimePercent = sbd.trackedPercent(decayedPercent(decayedUptime, decayedTotal))
+
+		overallUptime += serviceUptime
+		overallDowntime += serviceDowntime
+	}
+
+	snapshot.OverallUptimePercent = uptimePercent(overallUptime, overallDowntime)
+
+	return snapshot
+}
+
+// averageServiceUptime returns the average uptime of the scored services of the host
+func (hostState *HostState) averageServiceUptime() time.Duration {
+	var (
+		uptime time.Duration
+		scored int
+	)
+
+	for _, serviceState := range hostState.Services {
+		if serviceState.Service.IsScored() {
+			uptime += serviceState.Uptime
+			scored++
+		}
+	}
+
+	if scored == 0 {
+		return 0
+	}
+
+	return uptime / time.Duration(scored)
+}
+
+// FindHost returns the state of the host named hostName, or nil if there is no such host
+func (snapshot *StateSnapshot) FindHost(hostName string) *HostState {
+	for hostIndex := range snapshot.Hosts {
+		if snapshot.Hosts[hostIndex].Host.Name == hostName {
+			return &snapshot.Hosts[hostIndex]
+		}
+	}
+
+	return nil
+}
+
+// copyHosts returns a copy of the hosts in the snapshot that can be written to
+func (snapshot *StateSnapshot) copyHosts() []Host {
+	hosts := make([]Host, len(snapshot.Hosts))
+
+	for hostIndex := range snapshot.Hosts {
+		hosts[hostIndex] = snapshot.Hosts[hostIndex].Host
+		hosts[hostIndex].Services = make([]Service, len(snapshot.Hosts[hostIndex].Host.Services))
+		copy(hosts[hostIndex].Services, snapshot.Hosts[hostIndex].Host.Services)
+	}
+
+	return hosts
+}
+
+// indexStates returns the states of the hosts in the snapshot by host name, and the states
+// of their services by service ID
+func (snapshot *StateSnapshot) indexStates() (map[string]*HostState, map[string]*ServiceState) {
+	hostStates := make(map[string]*HostState, len(snapshot.Hosts))
+	serviceStates := make(map[string]*ServiceState)
+
+	for hostIndex := range snapshot.Hosts {
+		hostState := &snapshot.Hosts[hostIndex]
+		hostStates[hostState.Host.Name] = hostState
+
+		for serviceIndex := range hostState.Services {
+			serviceStates[hostState.Services[serviceIndex].Service.ID()] = &hostState.Services[serviceIndex]
+		}
+	}
+
+	return hostStates, serviceStates
+}
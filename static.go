@@ -0,0 +1,70 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultFavicon is the favicon served at /favicon.ico when StaticDirectory doesn't
+// have its own. It's a 16x16 ICO holding a PNG of a green check mark.
+var defaultFavicon = []byte{
+	0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x10, 0x10, 0x00, 0x00, 0x01, 0x00, 0x20, 0x00, 0x87, 0x00,
+	0x00, 0x00, 0x16, 0x00, 0x00, 0x00, 0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00,
+	0x00, 0x0d, 0x49, 0x48, 0x44, 0x52, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x10, 0x08, 0x06,
+	0x00, 0x00, 0x00, 0x1f, 0xf3, 0xff, 0x61, 0x00, 0x00, 0x00, 0x4e, 0x49, 0x44, 0x41, 0x54, 0x78,
+	0xda, 0x63, 0x60, 0x00, 0x02, 0x25, 0x25, 0xa5, 0xff, 0xe4, 0x60, 0x06, 0x4a, 0x34, 0xc3, 0x0d,
+	0x19, 0x7a, 0x06, 0xe8, 0x9d, 0x71, 0x00, 0x63, 0xb2, 0x0c, 0x80, 0x69, 0x26, 0xcb, 0x00, 0x6c,
+	0x9a, 0x89, 0x36, 0x00, 0x97, 0x66, 0x0c, 0x03, 0xb0, 0x29, 0xc2, 0xa7, 0x19, 0xa7, 0x01, 0x30,
+	0xc5, 0x84, 0x34, 0x63, 0xf5, 0x02, 0xb2, 0x26, 0x42, 0x9a, 0x71, 0x86, 0x01, 0xb1, 0x9a, 0x87,
+	0x68, 0x4a, 0xa4, 0x6a, 0x8e, 0x04, 0xe9, 0x05, 0x00, 0x14, 0xce, 0x78, 0x54, 0xf3, 0x65, 0x70,
+	0x09, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
+}
+
+// faviconResponder serves the favicon.ico of StaticDirectory if it has one, and
+// defaultFavicon otherwise, so that browsers aren't sent the whole scoreboard.
+func (sbd *State) faviconResponder(w http.ResponseWriter, r *http.Request) {
+	if sbd.Config.StaticDirectory != "" {
+		favicon := filepath.Join(sbd.Config.StaticDirectory, "favicon.ico")
+		if info, err := os.Stat(favicon); err == nil && info.Mode().IsRegular() {
+			http.ServeFile(w, r, favicon)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/x-icon")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeContent(w, r, "favicon.ico", time.Time{}, bytes.NewReader(defaultFavicon))
+}
+
+// staticResponder returns a handler that serves the files of StaticDirectory under /static/,
+// such as a competition logo to show on a custom scoreboard. Directories aren't listed.
+func (sbd *State) staticResponder() http.HandlerFunc {
+	fileServer := http.StripPrefix("/static/", http.FileServer(http.Dir(sbd.Config.StaticDirectory)))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		fileServer.ServeHTTP(w, r)
+	}
+}
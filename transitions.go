@@ -0,0 +1,161 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+)
+
+// The number of lines that can be waiting to be written to the TransitionLog before new
+// lines are dropped
+const transitionLogBufferLength = 1000
+
+// Transition is a change in the state of a host or service
+type Transition struct {
+	// Time is the time the state changed
+	Time time.Time `json:"time"`
+
+	// Host is the name of the host that changed state
+	Host string `json:"host"`
+
+	// Service is the name of the service that changed state. This is
+	// an empty string if the host itself changed state.
+	Service string `json:"service,omitempty"`
+
+	// From and To are the states before and after the change.
+	// Either 'up' or 'down'.
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Start is only set on the line of the TransitionLog that marks when scoring started,
+	// which is written every time scoring starts so the rounds of a log that several
+	// competitions were appended to can be told apart. Only Time is set on that line.
+	Start bool `json:"start,omitempty"`
+}
+
+// stateName returns the name of a state in a Transition
+func stateName(isUp bool) string {
+	if isUp {
+		return "up"
+	}
+
+	return "down"
+}
+
+// recordTransition records that a host or service changed state to isUp in Transitions,
+// and writes it to the TransitionLog if one is configured. serviceName is an empty string
+// if the host itself changed state. The caller must hold a write lock on serviceLock.
+func (sbd *State) recordTransition(hostName, serviceName string, isUp bool) {
+	transition := Transition{
+		Time:    time.Now(),
+		Host:    hostName,
+		Service: serviceName,
+		From:    stateName(!isUp),
+		To:      stateName(isUp),
+	}
+
+	sbd.Transitions = append(sbd.Transitions, transition)
+	sbd.logTransition(transition)
+}
+
+// recordScoringStart marks that scoring started at StartTime in the TransitionLog
+func (sbd *State) recordScoringStart() {
+	sbd.logTransition(Transition{Time: sbd.Config.StartTime, Start: true})
+}
+
+// logTransition queues transition to be appended to the TransitionLog if there is one. If
+// the queue is full, the transition is dropped from the log so StateUpdater never blocks.
+func (sbd *State) logTransition(transition Transition) {
+	if sbd.transitionLog == nil {
+		return
+	}
+
+	line, err := json.Marshal(transition)
+	if err != nil {
+		elog.Println("Failed to write to the transition log:", err)
+		return
+	}
+
+	select {
+	case sbd.transitionLog <- append(line, '\n'):
+	default:
+		elog.Println("Dropped a transition from the transition log because the queue is full:", string(line))
+	}
+}
+
+// openTransitionLog opens the TransitionLog and starts the TransitionLogWriter to append
+// transitions to it as they happen
+func (sbd *State) openTransitionLog() error {
+	file, err := os.OpenFile(sbd.Config.TransitionLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	sbd.transitionLog = make(chan []byte, transitionLogBufferLength)
+	go TransitionLogWriter(file, sbd.transitionLog)
+
+	return nil
+}
+
+// TransitionLogWriter is a thread that appends the queued lines of the TransitionLog to
+// file, so the file is never written to while serviceLock is held.
+func TransitionLogWriter(file *os.File, lines chan []byte) {
+	ilog.Println("Started the Transition Log Writer")
+
+	for line := range lines {
+		if _, err := file.Write(line); err != nil {
+			elog.Println("Failed to write to the transition log:", err)
+		}
+	}
+}
+
+// transitionsResponder serves the most recent transitions recorded during the competition
+// newest first as JSON Lines, with one JSON object per line. The number of transitions is
+// chosen by historyLimit. If the `limit` query parameter is 'all', every transition is
+// served oldest first instead, without being bound to maxHistoryLimit.
+func (sbd *State) transitionsResponder(w http.ResponseWriter, r *http.Request) {
+	var transitions []Transition
+
+	if r.URL.Query().Get("limit") == "all" {
+		sbd.serviceLock.RLock()
+		transitions = make([]Transition, len(sbd.Transitions))
+		copy(transitions, sbd.Transitions)
+		sbd.serviceLock.RUnlock()
+	} else {
+		limit, err := sbd.historyLimit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sbd.serviceLock.RLock()
+		indices := newestIndices(len(sbd.Transitions), limit)
+		transitions = make([]Transition, len(indices))
+		for index, transitionIndex := range indices {
+			transitions[index] = sbd.Transitions[transitionIndex]
+		}
+		sbd.serviceLock.RUnlock()
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	encoder := json.NewEncoder(w)
+	for _, transition := range transitions {
+		encoder.Encode(transition)
+	}
+}
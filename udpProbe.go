@@ -0,0 +1,283 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+)
+
+// The community that SNMP probes use when Command is empty
+const defaultSNMPCommunity = "public"
+
+// The largest UDP datagram that can be received
+const maxDatagramBytes = 65535
+
+// udpProbe builds a request for a UDP service and recognizes the service's reply
+// to that request. A UDP service is only up if it replies, as sending to a closed
+// UDP port usually fails silently.
+type udpProbe struct {
+	// request returns the datagram to send to the service
+	request func(service *Service) []byte
+
+	// isReply returns whether reply is a valid reply to request. If it isn't,
+	// the reason is returned.
+	isReply func(request, reply []byte) (bool, string)
+}
+
+// udpProbes are the probes that can be chosen with UDPProbe
+var udpProbes = map[string]udpProbe{
+	"ntp":  {ntpRequest, isNTPReply},
+	"dns":  {dnsRequest, isDNSReply},
+	"snmp": {snmpRequest, isSNMPReply},
+}
+
+// runUDPProbe sends the request of the Service's UDPProbe over conn and waits for a valid
+// reply. Datagrams that aren't a valid reply are skipped until the deadline of conn is
+// reached. If no valid reply is received, the details of the failure are returned.
+func (service *Service) runUDPProbe(conn net.Conn, maxBytes int64) (bool, string) {
+	probe := udpProbes[service.UDPProbe]
+	request := probe.request(service)
+
+	if _, err := conn.Write(request); err != nil {
+		return false, fmt.Sprintf("failed to send the %v request: %v", service.UDPProbe, err)
+	}
+
+	if maxBytes > maxDatagramBytes {
+		maxBytes = maxDatagramBytes
+	}
+
+	reply := make([]byte, maxBytes)
+	reason := "no reply"
+
+	for {
+		read, err := conn.Read(reply)
+		if err != nil {
+			return false, fmt.Sprintf("%v to the %v request: %v", reason, service.UDPProbe, err)
+		}
+
+		var ok bool
+		if ok, reason = probe.isReply(request, reply[:read]); ok {
+			return true, ""
+		}
+	}
+}
+
+// ntpRequest returns an NTP version 3 client request. The transmit timestamp is random so
+// that the server's reply can be matched to the request.
+func ntpRequest(service *Service) []byte {
+	request := make([]byte, 48)
+	request[0] = 0x1b // Leap indicator 0, version 3, mode 3 (client)
+	rand.Read(request[40:48])
+
+	return request
+}
+
+// isNTPReply returns whether reply is an NTP server reply to request. The server must not
+// be unsynchronized and must echo the request's transmit timestamp.
+func isNTPReply(request, reply []byte) (bool, string) {
+	if len(reply) < 48 {
+		return false, "a reply that is too short for NTP"
+	}
+
+	if mode := reply[0] & 0x07; mode != 4 {
+		return false, fmt.Sprintf("an NTP reply in mode %v instead of server mode", mode)
+	}
+
+	if !bytes.Equal(reply[24:32], request[40:48]) {
+		return false, "an NTP reply to a different request"
+	}
+
+	if stratum := reply[1]; stratum == 0 {
+		return false, "an NTP kiss-of-death reply"
+	}
+
+	return true, ""
+}
+
+// dnsRequest returns a recursive DNS query for the A record of the name in Command, or for
+// the NS records of the root zone if Command is empty.
+func dnsRequest(service *Service) []byte {
+	name := strings.Trim(service.Command, ". \r\n")
+	queryType := uint16(1) // A
+	if name == "" {
+		queryType = 2 // NS
+	}
+
+	request := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(request[0:2], uint16(rand.Intn(1<<16))) // ID
+	binary.BigEndian.PutUint16(request[2:4], 0x0100)                   // Recursion desired
+	binary.BigEndian.PutUint16(request[4:6], 1)                        // One question
+
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			request = append(request, byte(len(label)))
+			request = append(request, label...)
+		}
+	}
+
+	request = append(request, 0) // The root label ends the name
+	request = append(request, byte(queryType>>8), byte(queryType), 0, 1)
+
+	return request
+}
+
+// isDNSReply returns whether reply is a DNS response to request. Any response code counts,
+// as even a refused query shows that a DNS server is answering.
+func isDNSReply(request, reply []byte) (bool, string) {
+	if len(reply) < 12 {
+		return false, "a reply that is too short for DNS"
+	}
+
+	if !bytes.Equal(reply[0:2], request[0:2]) {
+		return false, "a DNS reply to a different query"
+	}
+
+	if reply[2]&0x80 == 0 {
+		return false, "a DNS query instead of a response"
+	}
+
+	return true, ""
+}
+
+// snmpRequest returns an SNMP v2c GetRequest for sysDescr.0, using Command as the
+// community, or 'public' if Command is empty.
+func snmpRequest(service *Service) []byte {
+	community := strings.TrimSpace(service.Command)
+	if community == "" {
+		community = defaultSNMPCommunity
+	}
+
+	// The first byte is kept from 1 to 127 so the request ID is a minimal positive integer
+	requestID := make([]byte, 4)
+	rand.Read(requestID)
+	requestID[0] = requestID[0]%127 + 1
+
+	sysDescr := []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00} // 1.3.6.1.2.1.1.1.0
+	varBind := berTLV(0x30, append(berTLV(0x06, sysDescr), 0x05, 0x00))
+
+	pdu := berTLV(0x02, requestID)
+	pdu = append(pdu, 0x02, 0x01, 0x00) // Error status
+	pdu = append(pdu, 0x02, 0x01, 0x00) // Error index
+	pdu = append(pdu, berTLV(0x30, varBind)...)
+
+	message := []byte{0x02, 0x01, 0x01} // Version 2c
+	message = append(message, berTLV(0x04, []byte(community))...)
+	message = append(message, berTLV(0xa0, pdu)...) // GetRequest
+
+	return berTLV(0x30, message)
+}
+
+// isSNMPReply returns whether reply is an SNMP GetResponse to request. An error status
+// still counts, as it shows that an SNMP agent is answering. A wrong community is
+// usually not answered at all.
+func isSNMPReply(request, reply []byte) (bool, string) {
+	requestID, err := snmpRequestID(request, 0xa0)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	replyID, err := snmpRequestID(reply, 0xa2)
+	if err != nil {
+		return false, fmt.Sprint("an invalid SNMP reply: ", err)
+	}
+
+	if replyID != requestID {
+		return false, "an SNMP reply to a different request"
+	}
+
+	return true, ""
+}
+
+// snmpRequestID returns the request ID of an SNMP message whose PDU has the tag pduTag
+func snmpRequestID(message []byte, pduTag byte) (int64, error) {
+	tag, content, _, err := berRead(message)
+	if err != nil || tag != 0x30 {
+		return 0, errors.New("the message isn't a sequence")
+	}
+
+	// Skip the version and the community
+	for _, expected := range []byte{0x02, 0x04} {
+		if tag, _, content, err = berRead(content); err != nil || tag != expected {
+			return 0, errors.New("the message has no version or community")
+		}
+	}
+
+	if tag, content, _, err = berRead(content); err != nil || tag != pduTag {
+		return 0, fmt.Errorf("the message isn't a PDU of type %#x", pduTag)
+	}
+
+	tag, idBytes, _, err := berRead(content)
+	if err != nil || tag != 0x02 || len(idBytes) == 0 || len(idBytes) > 8 {
+		return 0, errors.New("the PDU has no request ID")
+	}
+
+	var requestID int64
+	for _, idByte := range idBytes {
+		requestID = requestID<<8 | int64(idByte)
+	}
+
+	return requestID, nil
+}
+
+// berTLV encodes content as a BER value with the given tag
+func berTLV(tag byte, content []byte) []byte {
+	encoded := []byte{tag}
+
+	switch length := len(content); {
+	case length < 0x80:
+		encoded = append(encoded, byte(length))
+	case length <= 0xff:
+		encoded = append(encoded, 0x81, byte(length))
+	default:
+		encoded = append(encoded, 0x82, byte(length>>8), byte(length))
+	}
+
+	return append(encoded, content...)
+}
+
+// berRead decodes the first BER value in data, returning its tag, its content, and
+// the data that follows it.
+func berRead(data []byte) (byte, []byte, []byte, error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errors.New("truncated value")
+	}
+
+	tag, length, offset := data[0], int(data[1]), 2
+	if length&0x80 != 0 {
+		lengthBytes := length & 0x7f
+		if lengthBytes == 0 || lengthBytes > 2 || len(data) < 2+lengthBytes {
+			return 0, nil, nil, errors.New("unsupported length")
+		}
+
+		length = 0
+		for _, lengthByte := range data[2 : 2+lengthBytes] {
+			length = length<<8 | int(lengthByte)
+		}
+
+		offset += lengthBytes
+	}
+
+	if len(data) < offset+length {
+		return 0, nil, nil, errors.New("truncated value")
+	}
+
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
@@ -15,14 +15,120 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/user"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// The number of trailing characters of output kept when describing a failed check
+const detailsTailLength = 200
+
+// limitedBuffer is a bytes.Buffer that keeps at most limit bytes. Anything written
+// past the limit is discarded, but is still reported as written so that writers
+// such as a running command aren't interrupted.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int64
+}
+
+// Write implements io.Writer for limitedBuffer
+func (buffer *limitedBuffer) Write(data []byte) (int, error) {
+	if remaining := buffer.limit - int64(buffer.Len()); remaining > 0 {
+		if int64(len(data)) > remaining {
+			buffer.Buffer.Write(data[:remaining])
+		} else {
+			buffer.Buffer.Write(data)
+		}
+	}
+
+	return len(data), nil
+}
+
+// ReadFrom implements io.ReaderFrom for limitedBuffer. Commands write their output
+// with io.Copy, which would otherwise use the ReadFrom of bytes.Buffer and skip the limit.
+func (buffer *limitedBuffer) ReadFrom(reader io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{buffer}, reader)
+}
+
+// splitCommand splits a command line into the program and its arguments on runs of
+// whitespace. An argument can be wrapped in single or double quotes to keep the
+// whitespace in it, such as 'grep "two words" file', and quotes of the other kind
+// are kept inside of it. An error is returned if a quote isn't closed or the
+// command line is empty.
+func splitCommand(commandLine string) ([]string, error) {
+	var (
+		words   = make([]string, 0)
+		word    strings.Builder
+		inWord  = false
+		quote   rune
+		quoteAt int
+	)
+
+	for index, char := range commandLine {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(char)
+		case char == '"' || char == '\'':
+			quote = char
+			quoteAt = index
+			inWord = true
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(char)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("the quote at position %v is never closed", quoteAt+1)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	if len(words) == 0 {
+		return nil, fmt.Errorf("the command is empty")
+	}
+
+	return words, nil
+}
+
+// Utility function to return at most the last n bytes of a string
+func tail(str string, n int) string {
+	if len(str) > n {
+		return str[len(str)-n:]
+	}
+
+	return str
+}
+
+// Utility function to interpret the standard escapes \r, \n, \t, and \\
+// that were written literally into a string, such as in a single quoted
+// or unquoted YAML value.
+func interpretEscapes(str string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\r`, "\r",
+		`\n`, "\n",
+		`\t`, "\t",
+	).Replace(str)
+}
+
 // Utility function to translate a boolean flag to
 // the string representation of yes for true and
 // no for false
@@ -34,12 +140,37 @@ func boolToWord(flag bool) string {
 	return "no"
 }
 
+// listenPort returns the port of a 'host:port' listen address, such as ':80' or
+// '127.0.0.1:8080'. Port 0 asks for any free port.
+func listenPort(address string) (int, error) {
+	_, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, fmt.Errorf("%q is not a port number between 0 and 65535", portStr)
+	}
+
+	return port, nil
+}
+
 // This function tests privileges and initiates an unclean exit if the
-// incorrect privileges are used to run the program.
+// incorrect privileges are used to run the program. Ports from 1 to 1024 need
+// elevated privileges, while port 0 is given a free unprivileged port. The test
+// is skipped with -noprivcheck, as capabilities can grant these privileges to
+// users other than root.
 func testPrivileges(port int, pingHosts bool) {
-	if usr, err := user.Current(); err == nil && (pingHosts || port <= 1024) {
+	if noPrivCheck {
+		dlog.Println("Skipping the check for elevated privileges")
+		return
+	}
+
+	elevatedPort := port >= 1 && port <= 1024
+
+	if usr, err := user.Current(); err == nil && (pingHosts || elevatedPort) {
 		errStr := strings.Builder{}
-		elevatedPort := port <= 1024
 
 		errStr.WriteString("Please run with elevated privileges. This program needs " +
 			"elevated privileges to ")
@@ -71,7 +202,22 @@ func testPrivileges(port int, pingHosts bool) {
 
 // Simple function to format a time.Duration into a string
 func fmtDuration(duration time.Duration) string {
+	return formatDuration(duration, false)
+}
+
+// Simple function to format a time.Duration into a string
+// that includes days for durations longer than 24 hours
+func fmtDurationDays(duration time.Duration) string {
+	return formatDuration(duration, true)
+}
+
+// formatDuration formats a time.Duration into a string rounded to the second.
+// If showDays is set, durations longer than 24 hours are given a day component.
+func formatDuration(duration time.Duration, showDays bool) string {
+	const day = 24 * time.Hour
+
 	var (
+		days    time.Duration
 		hours   time.Duration
 		minutes time.Duration
 		seconds time.Duration
@@ -80,6 +226,12 @@ func fmtDuration(duration time.Duration) string {
 
 	duration = duration.Round(time.Second)
 
+	if showDays && duration >= day {
+		days = duration / day
+		duration -= days * day
+		builder.WriteString(fmt.Sprintf("%dd", days))
+	}
+
 	if duration >= time.Hour {
 		hours = duration / time.Hour
 		duration -= hours * time.Hour
@@ -97,3 +249,13 @@ func fmtDuration(duration time.Duration) string {
 
 	return builder.String()
 }
+
+// notBefore returns t, or earliest if t is before it, so that time before the
+// competition starts isn't counted
+func notBefore(t, earliest time.Time) time.Time {
+	if t.Before(earliest) {
+		return earliest
+	}
+
+	return t
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		showDays bool
+		want     string
+	}{
+		{0, false, "0s"},
+		{400 * time.Millisecond, false, "0s"},
+		{500 * time.Millisecond, false, "1s"},
+		{1499 * time.Millisecond, false, "1s"},
+		{59 * time.Second, false, "59s"},
+		{59*time.Second + 600*time.Millisecond, false, "1m0s"},
+		{time.Minute, false, "1m0s"},
+		{90 * time.Second, false, "1m30s"},
+		{time.Hour, false, "1h0s"},
+		{time.Hour + time.Second, false, "1h1s"},
+		{23*time.Hour + 59*time.Minute + 59*time.Second, true, "23h59m59s"},
+		{24 * time.Hour, false, "24h0s"},
+		{24 * time.Hour, true, "1d0s"},
+		{30 * time.Hour, false, "30h0s"},
+		{30 * time.Hour, true, "1d6h0s"},
+		{49*time.Hour + 30*time.Minute + 15*time.Second, true, "2d1h30m15s"},
+		{72*time.Hour + 5*time.Second, true, "3d5s"},
+	}
+
+	for _, test := range tests {
+		if got := formatDuration(test.duration, test.showDays); got != test.want {
+			t.Errorf("formatDuration(%v, %v) = %q, want %q", test.duration, test.showDays, got, test.want)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		commandLine string
+		want        []string
+	}{
+		{`a0001 LOGIN "sysadmin" "password"`, []string{"a0001", "LOGIN", "sysadmin", "password"}},
+		{`  dig   @10.0.0.53  www.example.lan  `, []string{"dig", "@10.0.0.53", "www.example.lan"}},
+		{`"/opt/my checks/run.sh" -x`, []string{"/opt/my checks/run.sh", "-x"}},
+		{`echo "" done`, []string{"echo", "", "done"}},
+		{`echo 'it"s'`, []string{"echo", `it"s`}},
+		{`grep --regexp="a b"c`, []string{"grep", "--regexp=a bc"}},
+	}
+
+	for _, test := range tests {
+		if got, err := splitCommand(test.commandLine); err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitCommand(%q) = %q, %v, want %q", test.commandLine, got, err, test.want)
+		}
+	}
+
+	for _, commandLine := range []string{"", "   ", `echo "unclosed`, `echo 'unclosed`} {
+		if got, err := splitCommand(commandLine); err == nil {
+			t.Errorf("splitCommand(%q) = %q, want an error", commandLine, got)
+		}
+	}
+}
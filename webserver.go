@@ -16,14 +16,122 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// The layout of the time given by the Now template function when no layout is given
+const clockLayout = "15:04:05"
+
+// adminLoginTemplate is the admin login page, which is parsed once when goscore starts
+var adminLoginTemplate = template.Must(template.New("adminLogin").Parse(adminLoginPage))
+
+// adminErrorsTemplate is the admin errors page, which is parsed once when goscore starts
+var adminErrorsTemplate = template.Must(template.New("adminErrors").Parse(adminErrorsPage))
+
+// scoreboardData is the data that is given to the scoreboard template.
+type scoreboardData struct {
+	Title            string
+	Hosts            []Host
+	PingHosts        bool
+	AccessibleColors bool
+	TimeLeft         time.Duration
+
+	// Annotations are the windows marked on the competition timeline
+	Annotations []Annotation
+
+	// Phase is the part of the competition that is happening, and StartsIn is
+	// the time until the competition starts.
+	Phase    CompetitionPhase
+	StartsIn time.Duration
+
+	// Initializing is set until every service has been checked once after scoring starts
+	Initializing bool
+
+	// Elapsed and OverallUptimePercent are taken from the snapshot the data was made from
+	Elapsed              time.Duration
+	OverallUptimePercent float64
+
+	// hostStates and serviceStates are the states of Hosts and their services from the
+	// snapshot, by host name and service ID. The template functions read these so that
+	// they don't take any locks, and they must not be written to since data is shared.
+	hostStates    map[string]*HostState
+	serviceStates map[string]*ServiceState
+
+	// Categories are the services of Hosts grouped by their Category, which are only
+	// set if GroupByCategory is set.
+	GroupByCategory bool
+	Categories      []Category
+
+	// The colors from the config. These were validated when the config was parsed.
+	UpColor         template.CSS
+	DownColor       template.CSS
+	BackgroundColor template.CSS
+
+	// Page is the page of hosts being shown and PageCount is the number of pages
+	// when PageSize is set. PrevPage and NextPage are links to the neighbouring
+	// pages, and are empty if there isn't one.
+	Page      int
+	PageCount int
+	PrevPage  string
+	NextPage  string
+}
+
+// hostDetail is the JSON representation of a single Host served by hostDetailResponder.
+type hostDetail struct {
+	Name                 string          `json:"name"`
+	IP                   string          `json:"ip"`
+	ActiveAddress        string          `json:"activeAddress,omitempty"`
+	IsUp                 bool            `json:"isUp"`
+	Uptime               string          `json:"uptime"`
+	Downtime             string          `json:"downtime"`
+	Score                int64           `json:"score"`
+	UptimeScore          int64           `json:"uptimeScore"`
+	BonusPoints          int64           `json:"bonusPoints"`
+	Bonuses              []Bonus         `json:"bonuses"`
+	ServiceUptimePercent float64         `json:"serviceUptimePercent"`
+	HealthPercent        float64         `json:"healthPercent"`
+	Services             []serviceDetail `json:"services"`
+
+	// WeightedUptimePercent is only set if UptimeHalfLife is set
+	WeightedUptimePercent *float64 `json:"weightedUptimePercent,omitempty"`
+}
+
+// serviceDetail is the JSON representation of a single Service contained within a hostDetail.
+type serviceDetail struct {
+	Name          string   `json:"name"`
+	Port          string   `json:"port"`
+	Protocol      string   `json:"protocol"`
+	Tags          []string `json:"tags"`
+	IsUp          bool     `json:"isUp"`
+	Inactive      bool     `json:"inactive"`
+	Scored        bool     `json:"scored"`
+	ActiveAddress string   `json:"activeAddress,omitempty"`
+	Overridden    bool     `json:"overridden"`
+	OverriddenBy  string   `json:"overriddenBy,omitempty"`
+	Uptime        string   `json:"uptime"`
+	Downtime      string   `json:"downtime"`
+	UptimePercent float64  `json:"uptimePercent"`
+	ChecksPassed  int64    `json:"checksPassed"`
+	ChecksTotal   int64    `json:"checksTotal"`
+
+	// WeightedUptimePercent is only set if UptimeHalfLife is set
+	WeightedUptimePercent *float64 `json:"weightedUptimePercent,omitempty"`
+
+	// The estimated median and 95th percentile durations of the checks of the
+	// service. These are only set once the service has been checked.
+	LatencyP50 string `json:"latencyP50,omitempty"`
+	LatencyP95 string `json:"latencyP95,omitempty"`
+}
+
 // WebContentUpdater is a thread that is started be Start() to update the web interface.
 // It updates the template every 5 seconds by default right now.
 func (sbd *State) WebContentUpdater(update, shutdown chan interface{}) {
@@ -35,146 +143,273 @@ func (sbd *State) WebContentUpdater(update, shutdown chan interface{}) {
 
 	ilog.Println("Started the Webpage Content Updater")
 
-	data := struct {
-		Title     string
-		Hosts     []Host
-		PingHosts bool
-		TimeLeft  time.Duration
-	}{}
+	data := scoreboardData{
+		PingHosts:        sbd.Config.PingHosts,
+		AccessibleColors: sbd.Config.AccessibleColors,
+		GroupByCategory:  sbd.Config.GroupByCategory,
+		UpColor:          template.CSS(sbd.Config.UpColor),
+		DownColor:        template.CSS(sbd.Config.DownColor),
+		BackgroundColor:  template.CSS(sbd.Config.BackgroundColor),
+	}
 
-	sbd.serviceLock.RLock()
+	// refresh updates data from a new snapshot of the state
+	refresh := func() {
+		snapshot := sbd.Snapshot()
 
-	data.Title = sbd.Name
+		data.Title = snapshot.Name
+		data.Annotations = snapshot.Annotations
+		data.TimeLeft = snapshot.TimeLeft
+		data.Phase = snapshot.Phase
+		data.StartsIn = snapshot.StartsIn
+		data.Initializing = snapshot.Initializing
+		data.Elapsed = snapshot.Elapsed
+		data.OverallUptimePercent = snapshot.OverallUptimePercent
+		data.hostStates, data.serviceStates = snapshot.indexStates()
 
-	data.Hosts = make([]Host, len(sbd.Hosts))
-	copy(data.Hosts, sbd.Hosts)
+		// Standings change over time so re-order the hosts
+		data.Hosts = sbd.sortHosts(sortServices(snapshot.copyHosts()), data.hostStates)
 
-	for i := range data.Hosts {
-		host := &(data.Hosts[i])
-		host.Services = make([]Service, len(sbd.Hosts[i].Services))
-		copy(host.Services, sbd.Hosts[i].Services)
+		if data.GroupByCategory {
+			data.Categories = groupByCategory(data.Hosts, data.PingHosts)
+		}
 	}
 
-	data.PingHosts = sbd.Config.PingHosts
-	data.TimeLeft = sbd.TimeLeft()
+	refresh()
 
-	sbd.serviceLock.RUnlock()
+	tmplt, err := template.New("scoreboard").Funcs(sbd.scoreboardFuncs(&data)).Parse(sbd.Config.ScoreboardDoc)
+	if err != nil {
+		elog.Println("Failed to parse the scoreboard template:", err)
+		os.Exit(1)
+	}
 
-	byteBuf := bytes.Buffer{}
+	// Share the template so that filtered pages can be generated on request. A template
+	// can't be cloned once it has been executed, so an unexecuted copy is shared.
+	sbd.scoreboardPageLock.Lock()
+	sbd.scoreboardTemplate = template.Must(tmplt.Clone())
+	sbd.scoreboardPageLock.Unlock()
 
-	upFunc := func(tracker interface{}) time.Duration {
-		var duration time.Duration
-		switch tracker.(type) {
-		case Host:
-			host := tracker.(Host)
-			duration = sbd.GetUptime(&host)
-		case Service:
-			service := tracker.(Service)
-			duration = sbd.GetUptime(&service)
-		default:
-			ilog.Println("Invalid use of Uptime function")
-			os.Exit(1)
+	// The last error from executing the template, so that a template that
+	// keeps failing is only logged when the error changes.
+	lastExecuteError := ""
+
+	// publish executes the template with data and updates the web sheet. If
+	// the template fails to execute, the last good page is kept so that the
+	// scoreboard stays up, even if it's stale. The data is shared too, which
+	// is safe because the hosts are replaced with a fresh copy on every
+	// update instead of being written to.
+	publish := func() {
+		byteBuf := bytes.Buffer{}
+		err := tmplt.Execute(&byteBuf, data)
+
+		if err == nil {
+			sbd.scoreboardPage.Store(byteBuf.Bytes())
 		}
 
-		return duration
+		sbd.scoreboardPageLock.Lock()
+		sbd.scoreboardData = data
+		sbd.scoreboardPageLock.Unlock()
+
+		if err != nil && err.Error() != lastExecuteError {
+			elog.Println("Failed to execute the scoreboard template, keeping the last good page:", err)
+			lastExecuteError = err.Error()
+		} else if err == nil && lastExecuteError != "" {
+			ilog.Println("The scoreboard template executed successfully again")
+			lastExecuteError = ""
+		}
 	}
 
-	downFunc := func(tracker interface{}) time.Duration {
-		var duration time.Duration
-		switch tracker.(type) {
-		case Host:
-			host := tracker.(Host)
-			duration = sbd.GetDowntime(&host)
-		case Service:
-			service := tracker.(Service)
-			duration = sbd.GetDowntime(&service)
+	for {
+		publish()
+
+		time.Sleep(1 * time.Second)
+
+		select {
+		case <-shutdown:
+			// Update the web sheet with the final data
+			refresh()
+			publish()
+
+			// Keep a static copy of the final standings for the record
+			if sbd.Config.SnapshotOnEnd {
+				if fileName, err := sbd.exportStaticScoreboard(); err == nil {
+					ilog.Println("Wrote the final scoreboard to", fileName)
+				} else {
+					elog.Println("Failed to write the final scoreboard:", err)
+				}
+			}
+
+			// Exit
+			ilog.Println("Shutting down the Webpage Content Updater")
+			return
+		case <-update:
+			// The snapshot below picks up the update
 		default:
-			ilog.Println("Invalid use of Downtime function")
-			os.Exit(1)
+			// Do nothing, just don't hang.
 		}
 
-		return duration
+		refresh()
 	}
+}
 
-	tmplt := template.Template{}
+// scoreboardFuncs returns the functions given to the scoreboard template. Uptimes, scores,
+// and percents are read from the states in data, so they agree with each other and
+// executing the template doesn't take any locks.
+func (sbd *State) scoreboardFuncs(data *scoreboardData) template.FuncMap {
+	hostState := func(host Host) (*HostState, error) {
+		if state, found := data.hostStates[host.Name]; found {
+			return state, nil
+		}
 
-	// Put a few basic functions into the template to make using templates easier
-	if newTemplate, err := template.New("scoreboard").Funcs(template.FuncMap{
-		"Uptime":         upFunc,
-		"Downtime":       downFunc,
-		"FormatDuration": fmtDuration,
-	}).Parse(sbd.Config.ScoreboardDoc); err == nil {
-		tmplt = *newTemplate
-	} else {
-		fmt.Println("ERRORED ON HTML TEMPLATE CREATION:", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("no state for the host %v", host.Name)
 	}
 
-	if err := tmplt.Execute(&byteBuf, data); err != nil {
-		fmt.Println("ERRORED ON HTML TEMPLATE EXECUTE:", err)
-		os.Exit(1)
+	serviceState := func(service Service) (*ServiceState, error) {
+		if state, found := data.serviceStates[service.ID()]; found {
+			return state, nil
+		}
+
+		return nil, fmt.Errorf("no state for the service %v", service.Name)
 	}
 
-	for {
-		// Update the web sheet with new data
-		sbd.scoreboardPageLock.Lock()
-		sbd.scoreboardPage = byteBuf.Bytes()
-		sbd.scoreboardPageLock.Unlock()
+	// times returns the uptime, downtime, and uptime percent of a Host or Service
+	times := func(function string, tracker interface{}) (time.Duration, time.Duration, float64, error) {
+		switch tracker := tracker.(type) {
+		case Host:
+			state, err := hostState(tracker)
+			if err != nil {
+				return 0, 0, 0, err
+			}
 
-		time.Sleep(1 * time.Second)
+			return state.Uptime, state.Downtime, state.UptimePercent, nil
+		case Service:
+			state, err := serviceState(tracker)
+			if err != nil {
+				return 0, 0, 0, err
+			}
 
-		// Clear the buffer for new data
-		byteBuf.Reset()
+			return state.Uptime, state.Downtime, state.UptimePercent, nil
+		default:
+			return 0, 0, 0, fmt.Errorf("invalid use of %v function on %T", function, tracker)
+		}
+	}
 
-		select {
-		case <-shutdown:
-			// Establish a read-only serviceLock to the scoreboard to retrieve data,
-			// then drop the serviceLock after we have retrieved that data we need.
-			sbd.serviceLock.RLock()
-
-			copy(data.Hosts, sbd.Hosts)
-			for i := range data.Hosts {
-				host := &(data.Hosts[i])
-				copy(host.Services, sbd.Hosts[i].Services)
+	uptimePercentFunc := func(tracker interface{}) (float64, error) {
+		_, _, percent, err := times("UptimePercent", tracker)
+		return percent, err
+	}
+
+	// percentOrZero returns the value of a percent that is only tracked if UptimeHalfLife is set
+	percentOrZero := func(percent *float64) float64 {
+		if percent == nil {
+			return 0
+		}
+
+		return *percent
+	}
+
+	return template.FuncMap{
+		"Uptime": func(tracker interface{}) (time.Duration, error) {
+			uptime, _, _, err := times("Uptime", tracker)
+			return uptime, err
+		},
+		"Downtime": func(tracker interface{}) (time.Duration, error) {
+			_, downtime, _, err := times("Downtime", tracker)
+			return downtime, err
+		},
+		"Score": func(host Host) (int64, error) {
+			state, err := hostState(host)
+			if err != nil {
+				return 0, err
 			}
-			data.TimeLeft = sbd.TimeLeft()
 
-			sbd.serviceLock.RUnlock()
+			return state.Score, nil
+		},
+		"UptimeScore": func(host Host) (int64, error) {
+			state, err := hostState(host)
+			if err != nil {
+				return 0, err
+			}
 
-			// Update the template with the new data
-			tmplt.Execute(&byteBuf, data)
+			return state.UptimeScore, nil
+		},
+		"UptimePercent": uptimePercentFunc,
+		"ServiceUptimePercent": func(host Host) (float64, error) {
+			state, err := hostState(host)
+			if err != nil {
+				return 0, err
+			}
 
-			// Update the web sheet with that data
-			sbd.scoreboardPageLock.Lock()
-			sbd.scoreboardPage = byteBuf.Bytes()
-			sbd.scoreboardPageLock.Unlock()
+			return state.ServiceUptimePercent, nil
+		},
+		"HostHealth": func(host Host) (float64, error) {
+			state, err := hostState(host)
+			if err != nil {
+				return 0, err
+			}
 
-			// Exit
-			ilog.Println("Shutting down the Webpage Content Updater")
-			return
-		case <-update:
-			// Establish a read-only serviceLock to the scoreboard to retrieve data,
-			// then drop the serviceLock after we have retrieved that data we need.
-			sbd.serviceLock.RLock()
-
-			copy(data.Hosts, sbd.Hosts)
-			for i := range data.Hosts {
-				host := &(data.Hosts[i])
-				copy(host.Services, sbd.Hosts[i].Services)
+			return state.HealthPercent, nil
+		},
+		"WeightedUptimePercent": func(service Service) (float64, error) {
+			state, err := serviceState(service)
+			if err != nil {
+				return 0, err
 			}
 
-			sbd.serviceLock.RUnlock()
-		default:
-			// Do nothing, just don't hang.
-		}
+			return percentOrZero(state.WeightedUptimePercent), nil
+		},
+		"HostWeightedUptimePercent": func(host Host) (float64, error) {
+			state, err := hostState(host)
+			if err != nil {
+				return 0, err
+			}
+
+			return percentOrZero(state.WeightedUptimePercent), nil
+		},
+		"OverallUptimePercent": func() float64 {
+			return data.OverallUptimePercent
+		},
+		"Checks": func(service Service) string {
+			return fmt.Sprintf("%v / %v", service.ChecksPassed(), service.ChecksTotal())
+		},
+		"UptimeClass": func(tracker interface{}) (string, error) {
+			percent, err := uptimePercentFunc(tracker)
+			return sbd.UptimeClass(percent), err
+		},
+		"Now": func(layout ...string) string {
+			if len(layout) > 0 {
+				return time.Now().Format(layout[0])
+			}
+
+			return time.Now().Format(clockLayout)
+		},
+		"Elapsed": func() time.Duration {
+			return data.Elapsed
+		},
+		"FormatDuration":     fmtDuration,
+		"FormatDurationDays": fmtDurationDays,
+	}
+}
 
-		// Safe because TimeLeft() is a read only function on data that
-		// doesn't change for the life of program.
-		data.TimeLeft = sbd.TimeLeft()
+// isAdmin determines if the request was made by a client that has logged in to the admin panel,
+// or that presented a verified client certificate if admin client certificates are configured.
+func (sbd *State) isAdmin(r *http.Request) bool {
+	// Client certificates replace the username and password login
+	if sbd.Config.AdminTLSConfig != nil {
+		return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+	}
+
+	cookie, err := r.Cookie(sbd.Config.AdminName)
+	return err == nil && cookie.Value == sbd.Config.AdminPassword
+}
 
-		// Update the template with the new data
-		tmplt.Execute(&byteBuf, data)
+// adminIdentity returns who an admin request was made by. This is the common name of the
+// client certificate if admin client certificates are configured, or the admin username.
+func (sbd *State) adminIdentity(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName
 	}
+
+	return sbd.Config.AdminName
 }
 
 // adminPanel serves both a login page for the admin panel and the admin panel itself.
@@ -182,19 +417,20 @@ func (sbd *State) WebContentUpdater(update, shutdown chan interface{}) {
 // unauthorized users and can authenticate authorized users.
 func (sbd *State) adminPanel(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		if cookie, err := r.Cookie(sbd.Config.AdminName); err == nil && cookie.Value == sbd.Config.AdminPassword {
+		if sbd.isAdmin(r) {
 			// Send admin home page
 			w.Write([]byte("LOGGED IN"))
 		} else {
 			// Send admin login page
-			io.Copy(w, bytes.NewBufferString(adminLoginPage))
+			sbd.adminLoginResponder(w)
 		}
 	} else if r.Method == "POST" {
 		// Determine if login or post from admin home page
-		if err := r.ParseForm(); err == nil {
+		if err := r.ParseForm(); err == nil && r.PostForm.Get("username") == sbd.Config.AdminName &&
+			r.PostForm.Get("password") == sbd.Config.AdminPassword {
 			http.SetCookie(w, &http.Cookie{
-				Name:  "admin",
-				Value: "password",
+				Name:  sbd.Config.AdminName,
+				Value: sbd.Config.AdminPassword,
 			})
 
 			r.Method = "GET"
@@ -209,10 +445,641 @@ func (sbd *State) adminPanel(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// scoreboardResponder serves the `index.html` for the scoreboard.
+// adminLoginResponder serves the admin login page with the AdminLoginMessage.
+func (sbd *State) adminLoginResponder(w http.ResponseWriter) {
+	data := struct {
+		Message string
+	}{
+		Message: sbd.Config.AdminLoginMessage,
+	}
+
+	if err := adminLoginTemplate.Execute(w, data); err != nil {
+		elog.Println("Failed to execute the admin login page:", err)
+	}
+}
+
+// adminErrorsPanel serves a table of the details of the last check of every service
+// to clients that have logged in to the admin panel.
+func (sbd *State) adminErrorsPanel(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Redirect(w, r, "/admin", http.StatusFound)
+		return
+	}
+
+	type checkError struct {
+		Host    string
+		Service string
+		IsUp    bool
+		Details string
+	}
+
+	data := struct {
+		Title  string
+		Errors []checkError
+	}{}
+
+	snapshot := sbd.Snapshot()
+
+	data.Title = snapshot.Name
+	for _, hostState := range snapshot.Hosts {
+		for _, serviceState := range hostState.Services {
+			data.Errors = append(data.Errors, checkError{
+				Host:    hostState.Host.Name,
+				Service: serviceState.Service.Name,
+				IsUp:    serviceState.Service.IsUp(),
+				Details: serviceState.Service.LastCheckDetails(),
+			})
+		}
+	}
+
+	if err := adminErrorsTemplate.Execute(w, data); err != nil {
+		elog.Println("Failed to execute the admin errors page:", err)
+	}
+}
+
+// loadScoreboardPage returns the last scoreboard page that WebContentUpdater rendered,
+// or nil if it hasn't rendered one yet. The page must not be written to.
+func (sbd *State) loadScoreboardPage() []byte {
+	page, _ := sbd.scoreboardPage.Load().([]byte)
+
+	return page
+}
+
+// writeScoreboardPage writes the last scoreboard page that WebContentUpdater rendered,
+// or a page saying that the scoreboard is starting if it hasn't rendered one yet.
+func (sbd *State) writeScoreboardPage(w http.ResponseWriter) {
+	page := sbd.loadScoreboardPage()
+	if page == nil {
+		page = []byte(startingPage)
+	}
+
+	sbd.writeScoreboardStatus(w)
+	w.Write(page)
+}
+
+// writeScoreboardStatus writes the status 503 Service Unavailable, with a Retry-After header
+// of when the competition starts, if UnavailableBeforeStart is set and the competition
+// hasn't started yet. Otherwise the status is left as 200 OK.
+func (sbd *State) writeScoreboardStatus(w http.ResponseWriter) {
+	if !sbd.Config.UnavailableBeforeStart {
+		return
+	}
+
+	sbd.serviceLock.RLock()
+	phase := sbd.Phase()
+	startsIn := sbd.StartsIn()
+	sbd.serviceLock.RUnlock()
+
+	if phase != PhasePreStart {
+		return
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int((startsIn+time.Second-1)/time.Second)))
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// scoreboardResponder serves the `index.html` for the scoreboard. If the `tags` query
+// parameter is given as a comma separated list, only services with one of those tags are shown.
+// If the `down` query parameter is given, only services that are currently down are shown, which
+// includes the services of hosts that are down if PingHosts is set.
+// If PageSize is set, the hosts are split into pages that are chosen with the `page` query parameter.
+// If UnavailableBeforeStart is set, the page is served with the status 503 until the competition starts.
+// The scoreboard is only served at exactly `/`, and every other path that isn't routed is not found.
 // Implements scoreboardResponder for State
 func (sbd *State) scoreboardResponder(w http.ResponseWriter, r *http.Request) {
+	// `/` is routed as a catch-all, so unknown paths end up here too
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	filter := parseServiceFilter(r, sbd.Config.PingHosts)
+
+	// The whole scoreboard is served without taking a lock
+	if filter.isEmpty() && sbd.Config.PageSize == 0 {
+		sbd.writeScoreboardPage(w)
+		return
+	}
+
 	sbd.scoreboardPageLock.RLock()
-	io.Copy(w, bytes.NewReader(sbd.scoreboardPage))
+
+	if sbd.scoreboardTemplate == nil {
+		sbd.scoreboardPageLock.RUnlock()
+		sbd.writeScoreboardPage(w)
+		return
+	}
+
+	data := sbd.scoreboardData
+	tmplt, err := sbd.scoreboardTemplate.Clone()
+
 	sbd.scoreboardPageLock.RUnlock()
+
+	if err != nil {
+		elog.Println("Failed to copy the scoreboard template:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// The template functions of the copy read the states from this data
+	tmplt.Funcs(sbd.scoreboardFuncs(&data))
+
+	if !filter.isEmpty() {
+		data.Hosts = filterHosts(data.Hosts, filter)
+	}
+
+	if sbd.Config.PageSize > 0 {
+		paginate(&data, r, sbd.Config.PageSize)
+	}
+
+	// Only group the services that are left on the page
+	if data.GroupByCategory {
+		data.Categories = groupByCategory(data.Hosts, data.PingHosts)
+	}
+
+	byteBuf := bytes.Buffer{}
+	if err := tmplt.Execute(&byteBuf, data); err != nil {
+		elog.Println("Failed to execute the filtered scoreboard:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	sbd.writeScoreboardStatus(w)
+	io.Copy(w, &byteBuf)
+}
+
+// paginate limits the hosts of data to the page given by the `page` query parameter of a
+// request, with pageSize hosts on each page, and links the neighbouring pages. Pages start
+// at 1 and out of range pages show the closest page.
+func paginate(data *scoreboardData, r *http.Request, pageSize int) {
+	data.PageCount = (len(data.Hosts) + pageSize - 1) / pageSize
+	if data.PageCount < 1 {
+		data.PageCount = 1
+	}
+
+	data.Page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if data.Page < 1 {
+		data.Page = 1
+	} else if data.Page > data.PageCount {
+		data.Page = data.PageCount
+	}
+
+	start := (data.Page - 1) * pageSize
+	end := start + pageSize
+	if end > len(data.Hosts) {
+		end = len(data.Hosts)
+	}
+
+	data.Hosts = data.Hosts[start:end]
+
+	// Keep the other query parameters, such as tags, in the links to other pages
+	pageLink := func(page int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		return "?" + query.Encode()
+	}
+
+	if data.Page > 1 {
+		data.PrevPage = pageLink(data.Page - 1)
+	}
+
+	if data.Page < data.PageCount {
+		data.NextPage = pageLink(data.Page + 1)
+	}
+}
+
+// serviceFilter selects the services shown by the scoreboard and the JSON API
+type serviceFilter struct {
+	// tags are the tags a service must have at least one of to be shown.
+	// If there are no tags, services are shown regardless of their tags.
+	tags []string
+
+	// downOnly is a flag that if true, only shows services that are down
+	downOnly bool
+
+	// pingHosts is a flag that if true, counts the services of hosts that are down as down
+	pingHosts bool
+}
+
+// parseServiceFilter returns the serviceFilter given by the query parameters of a request.
+// Tags are given in the comma separated `tags` query parameter, and only services that are
+// down are shown if the `down` query parameter is set to anything other than '0' or 'false'.
+// If pingHosts is set, services of hosts that are down are down as well.
+func parseServiceFilter(r *http.Request, pingHosts bool) serviceFilter {
+	filter := serviceFilter{tags: make([]string, 0), pingHosts: pingHosts}
+
+	for _, tag := range strings.Split(r.URL.Query().Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.tags = append(filter.tags, tag)
+		}
+	}
+
+	if down := r.URL.Query().Get("down"); down != "" && down != "0" && down != "false" {
+		filter.downOnly = true
+	}
+
+	return filter
+}
+
+// isEmpty returns whether the serviceFilter shows every service
+func (filter serviceFilter) isEmpty() bool {
+	return len(filter.tags) == 0 && !filter.downOnly
+}
+
+// matches returns whether service of host is shown by the serviceFilter
+func (filter serviceFilter) matches(host *Host, service *Service) bool {
+	if len(filter.tags) > 0 && !service.HasAnyTag(filter.tags) {
+		return false
+	}
+
+	return !filter.downOnly || !service.IsUp() || (filter.pingHosts && !host.IsUp())
+}
+
+// filterHosts returns a copy of hosts that only contains the services that match
+// filter. Hosts that are left without services are removed.
+func filterHosts(hosts []Host, filter serviceFilter) []Host {
+	filteredHosts := make([]Host, 0, len(hosts))
+
+	for _, host := range hosts {
+		services := make([]Service, 0, len(host.Services))
+		for serviceIndex := range host.Services {
+			if filter.matches(&host, &host.Services[serviceIndex]) {
+				services = append(services, host.Services[serviceIndex])
+			}
+		}
+
+		if len(services) > 0 {
+			host.Services = services
+			filteredHosts = append(filteredHosts, host)
+		}
+	}
+
+	return filteredHosts
+}
+
+// hostDetailResponder serves the JSON details of a single host. The host is identified by the
+// remainder of the path after `/api/host/`. If no host matches, a 404 is sent. Services can be
+// filtered with the `tags` and `down` query parameters the same way as scoreboardResponder.
+func (sbd *State) hostDetailResponder(w http.ResponseWriter, r *http.Request) {
+	hostName := strings.TrimPrefix(r.URL.Path, "/api/host/")
+	filter := parseServiceFilter(r, sbd.Config.PingHosts)
+
+	snapshot := sbd.Snapshot()
+
+	hostState := snapshot.FindHost(hostName)
+	if hostState == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	host := &hostState.Host
+	detail := hostDetail{
+		Name:                 host.Name,
+		IP:                   host.IP,
+		ActiveAddress:        host.ActiveAddress(),
+		IsUp:                 host.IsUp(),
+		Uptime:               fmtDuration(hostState.Uptime),
+		Downtime:             fmtDuration(hostState.Downtime),
+		Score:                hostState.Score,
+		UptimeScore:          hostState.UptimeScore,
+		BonusPoints:          host.BonusPoints(),
+		Bonuses:              hostState.Bonuses,
+		ServiceUptimePercent: hostState.ServiceUptimePercent,
+		HealthPercent:        hostState.HealthPercent,
+		Services:             make([]serviceDetail, 0, len(hostState.Services)),
+
+		WeightedUptimePercent: hostState.WeightedUptimePercent,
+	}
+
+	for _, serviceState := range hostState.Services {
+		service := serviceState.Service
+
+		if !filter.matches(host, service) {
+			continue
+		}
+
+		detail.Services = append(detail.Services, serviceDetail{
+			Name:          service.Name,
+			Port:          service.Port,
+			Protocol:      service.Protocol,
+			Tags:          service.Tags,
+			IsUp:          service.IsUp(),
+			Inactive:      !service.IsActive(),
+			Scored:        service.IsScored(),
+			ActiveAddress: service.ActiveAddress(),
+			Overridden:    service.IsOverridden(),
+			OverriddenBy:  service.OverriddenBy(),
+			Uptime:        fmtDuration(serviceState.Uptime),
+			Downtime:      fmtDuration(serviceState.Downtime),
+			UptimePercent: serviceState.UptimePercent,
+			ChecksPassed:  service.ChecksPassed(),
+			ChecksTotal:   service.ChecksTotal(),
+
+			WeightedUptimePercent: serviceState.WeightedUptimePercent,
+			LatencyP50:            fmtLatency(serviceState.Latency.quantile(0.5, sbd.Config.LatencyBuckets)),
+			LatencyP95:            fmtLatency(serviceState.Latency.quantile(0.95, sbd.Config.LatencyBuckets)),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// snapshotResponder serves the JSON list of score snapshots taken during the competition.
+func (sbd *State) snapshotResponder(w http.ResponseWriter, r *http.Request) {
+	sbd.serviceLock.RLock()
+	snapshots := make([]ScoreSnapshot, len(sbd.Snapshots))
+	copy(snapshots, sbd.Snapshots)
+	sbd.serviceLock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// adminEventsResponder serves the most recent entries of the JSON event log newest first to
+// clients that have logged in to the admin panel. The number of events is chosen by historyLimit.
+func (sbd *State) adminEventsResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	limit, err := sbd.historyLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sbd.serviceLock.RLock()
+	indices := newestIndices(len(sbd.Events), limit)
+	events := make([]Event, len(indices))
+	for index, eventIndex := range indices {
+		events[index] = sbd.Events[eventIndex]
+	}
+	sbd.serviceLock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// adminOverrideResponder forces the state of the service given by the `host` and `service`
+// form values to the `state` form value, which is either 'up' or 'down'. The service keeps
+// this state until the override is cleared by adminClearOverrideResponder. A change of state
+// is recorded and announced the same way as one made by a check.
+func (sbd *State) adminOverrideResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := r.FormValue("state")
+	if state != "up" && state != "down" {
+		http.Error(w, "state must be either 'up' or 'down'", http.StatusBadRequest)
+		return
+	}
+
+	admin := sbd.adminIdentity(r)
+
+	sbd.serviceLock.Lock()
+	defer sbd.serviceLock.Unlock()
+
+	host, service := sbd.findService(r.FormValue("host"), r.FormValue("service"))
+	if service == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	details := fmt.Sprintf("State overridden to %v by %v", state, admin)
+
+	wasUp := service.IsUp()
+	service.Override(state == "up", admin)
+	if service.IsUp() != wasUp {
+		sbd.stateChanged(host, service, service.IsUp())
+	}
+
+	// Consumers of the feed see the override like any other update of the service
+	if sbd.updateFeed != nil {
+		sbd.feedUpdate(ServiceUpdate{host.IP, true, service.IsUp(), service.ID(), "", details, "", 0, false},
+			host, service, wasUp)
+	}
+
+	sbd.logEvent(host.Name, service.Name, details)
+	sbd.signalUpdate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminExtendResponder adds the `duration` form value, such as '30m' or '+30m', to the time
+// left in the competition. If the competition has already ended, scoring is restarted.
+func (sbd *State) adminExtendResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	extension, err := parseDuration(strings.TrimPrefix(r.FormValue("duration"), "+"))
+	if err != nil || extension <= 0 {
+		http.Error(w, "duration must be a positive duration such as '30m'", http.StatusBadRequest)
+		return
+	}
+
+	sbd.ExtendCompetition(extension, sbd.adminIdentity(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminResetResponder resets scoring to start a new round of the competition. If the `round`
+// form value is given, it is appended to the name of the competition.
+func (sbd *State) adminResetResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	sbd.ResetScoring(strings.TrimSpace(r.FormValue("round")), sbd.adminIdentity(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminExportResponder writes the currently rendered scoreboard to a static HTML file
+// and responds with the name of that file.
+func (sbd *State) adminExportResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	fileName, err := sbd.exportStaticScoreboard()
+	if err != nil {
+		elog.Println("Failed to export the scoreboard:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	ilog.Printf("%v exported the scoreboard to %v\n", sbd.adminIdentity(r), fileName)
+
+	w.Write([]byte(fileName))
+}
+
+// adminClearOverrideResponder clears the override of the service given by the `host` and
+// `service` form values so that checks can change its state again.
+func (sbd *State) adminClearOverrideResponder(w http.ResponseWriter, r *http.Request) {
+	if !sbd.isAdmin(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin := sbd.adminIdentity(r)
+
+	sbd.serviceLock.Lock()
+	defer sbd.serviceLock.Unlock()
+
+	host, service := sbd.findService(r.FormValue("host"), r.FormValue("service"))
+	if service == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	service.ClearOverride()
+	sbd.logEvent(host.Name, service.Name, fmt.Sprint("State override cleared by ", admin))
+	sbd.signalUpdate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// configResponder serves a JSON description of the hosts and services that are scored.
+// Commands, responses, and headers are left out because they can hold credentials.
+func (sbd *State) configResponder(w http.ResponseWriter, r *http.Request) {
+	type serviceTarget struct {
+		Name     string   `json:"name"`
+		Protocol string   `json:"protocol"`
+		Port     string   `json:"port,omitempty"`
+		Tags     []string `json:"tags,omitempty"`
+	}
+
+	type hostTarget struct {
+		Name     string          `json:"name"`
+		IP       string          `json:"ip,omitempty"`
+		IPv6     string          `json:"ipv6,omitempty"`
+		BackupIP string          `json:"backupIP,omitempty"`
+		Services []serviceTarget `json:"services"`
+	}
+
+	targets := struct {
+		Name      string       `json:"name"`
+		PingHosts bool         `json:"pingHosts"`
+		Hosts     []hostTarget `json:"hosts"`
+	}{}
+
+	sbd.serviceLock.RLock()
+
+	targets.Name = sbd.Name
+	targets.PingHosts = sbd.Config.PingHosts
+	targets.Hosts = make([]hostTarget, 0, len(sbd.Hosts))
+	for hostIndex := range sbd.Hosts {
+		host := &sbd.Hosts[hostIndex]
+
+		target := hostTarget{
+			Name:     host.Name,
+			IP:       host.IP,
+			IPv6:     host.IPv6,
+			BackupIP: host.BackupIP,
+			Services: make([]serviceTarget, 0, len(host.Services)),
+		}
+
+		for serviceIndex := range host.Services {
+			service := &host.Services[serviceIndex]
+
+			target.Services = append(target.Services, serviceTarget{
+				Name:     service.Name,
+				Protocol: service.Protocol,
+				Port:     service.Port,
+				Tags:     service.Tags,
+			})
+		}
+
+		targets.Hosts = append(targets.Hosts, target)
+	}
+
+	sbd.serviceLock.RUnlock()
+
+	// The targets don't change during the competition, so clients can cache them
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(targets)
+}
+
+// versionResponder serves the build information of this program as JSON, so that
+// the build running on a scoreboard can be confirmed remotely.
+func (sbd *State) versionResponder(w http.ResponseWriter, r *http.Request) {
+	buildInfo := struct {
+		Version   string `json:"version"`
+		Commit    string `json:"commit"`
+		BuildDate string `json:"buildDate"`
+		GoVersion string `json:"goVersion"`
+	}{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildInfo)
+}
+
+// uptimeResponder serves the JSON uptime percentage of the services of every host and of the
+// competition overall.
+func (sbd *State) uptimeResponder(w http.ResponseWriter, r *http.Request) {
+	type hostUptime struct {
+		Name                  string   `json:"name"`
+		ServiceUptimePercent  float64  `json:"serviceUptimePercent"`
+		HealthPercent         float64  `json:"healthPercent"`
+		WeightedUptimePercent *float64 `json:"weightedUptimePercent,omitempty"`
+	}
+
+	uptime := struct {
+		OverallUptimePercent float64      `json:"overallUptimePercent"`
+		Hosts                []hostUptime `json:"hosts"`
+	}{}
+
+	snapshot := sbd.Snapshot()
+
+	uptime.OverallUptimePercent = snapshot.OverallUptimePercent
+	uptime.Hosts = make([]hostUptime, 0, len(snapshot.Hosts))
+	for _, hostState := range snapshot.Hosts {
+		uptime.Hosts = append(uptime.Hosts, hostUptime{
+			Name:                  hostState.Host.Name,
+			ServiceUptimePercent:  hostState.ServiceUptimePercent,
+			HealthPercent:         hostState.HealthPercent,
+			WeightedUptimePercent: hostState.WeightedUptimePercent,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(uptime)
 }
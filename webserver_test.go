@@ -0,0 +1,51 @@
+// Copyright 2019 Michael Mitchell
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUnavailableBeforeStart(t *testing.T) {
+	sbd := NewScoreboard()
+	sbd.Config.CompetitionDuration = time.Hour
+	sbd.Config.UnavailableBeforeStart = true
+
+	get := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		sbd.scoreboardResponder(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		return recorder
+	}
+
+	sbd.Config.ScheduledStart = time.Now().Add(time.Minute)
+	sbd.startScoring()
+
+	if response := get(); response.Code != http.StatusServiceUnavailable {
+		t.Errorf("before startTime: got status %v, want %v", response.Code, http.StatusServiceUnavailable)
+	} else if retryAfter := response.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Errorf("before startTime: got Retry-After %q, want \"60\"", retryAfter)
+	}
+
+	sbd.Config.ScheduledStart = time.Now().Add(-time.Minute)
+	sbd.startScoring()
+
+	if response := get(); response.Code != http.StatusOK {
+		t.Errorf("after startTime: got status %v, want %v", response.Code, http.StatusOK)
+	}
+}